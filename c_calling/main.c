@@ -4,9 +4,20 @@
 
 #define int64 long long
 
+typedef struct {
+    int64 device_id;
+    int kind;
+    double as_double;
+    int64 as_int;
+    char as_string[128];
+    int64 timestamp_ns;
+    char labels[128];
+} tpu_metric_sample;
+
 int (*tpu_chip_count)(void);
 int (*tpu_metrics)(int port, int64 *device_ids, int64 *memory_usage, int64 *total_memory, double *duty_cycle_pct, int n);
 int (*tpu_pids)(int64 *pids, int n);
+int (*tpu_metric_by_name)(int port, const char *name, tpu_metric_sample *samples, int n, int *count);
 
 char *libname = "libtpuinfo.so";
 
@@ -38,6 +49,11 @@ int resolve_symbols() {
     error_msg = dlerror();
     if (error_msg != NULL) goto cleanup;
 
+    snprintf(symbol_name, sizeof(symbol_name), "%s", "tpu_metric_by_name");
+    tpu_metric_by_name = dlsym(handle, "tpu_metric_by_name");
+    error_msg = dlerror();
+    if (error_msg != NULL) goto cleanup;
+
     return 0;
 
     cleanup:
@@ -68,5 +84,15 @@ int main() {
     for (int i = 0; i < n; i ++) {
         fprintf(stderr, "%lld %lld %lld %.2f\n", device_ids[i], memory_usage[i], total_memory[i], duty_cycle_pct[i]);
     }
+
+    tpu_metric_sample samples[32];
+    int count = 0;
+    if (tpu_metric_by_name(-1, "tpu.runtime.tensorcore.dutycycle.percent", samples, 32, &count) != 0) {
+        fprintf(stderr, "Error retrieving metric by name\n");
+        return 1;
+    }
+    for (int i = 0; i < count && i < 32; i ++) {
+        fprintf(stderr, "%s %s (kind %d)\n", samples[i].labels, samples[i].as_string, samples[i].kind);
+    }
     return 0;
 }
@@ -0,0 +1,491 @@
+// Command tpuinfo prints the TPU chips, their owning processes and the TPU
+// runtime metrics of this host.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/rdyro/libtpuinfo/tpuinfo"
+)
+
+type command struct {
+	help string
+	run  func(ctx context.Context, client *tpuinfo.Client, args []string) error
+}
+
+var commands = map[string]command{
+	"chips":     {"list the TPU chips on this host", runChips},
+	"devices":   {"list every TPU chip with its PCI address, NUMA node and /dev node", runDevices},
+	"topology":  {"draw the TPU chips of this host in their host bounds with their ICI links", runTopology},
+	"owners":    {"list every process holding a TPU chip, with its descriptors", runOwners},
+	"ps":        {"list the processes using TPU chips with their user, CPU, memory and command", runProcesses},
+	"pods":      {"attribute TPU chips to Kubernetes pods: pods [-socket KUBELET_SOCKET]", runPods},
+	"gres":      {"print a Slurm gres.conf fragment for the TPU chips of this host", runGres},
+	"index":     {"print the device index shared by every API: index -> PCI address, /dev node, runtime device id", runIndex},
+	"metrics":   {"print memory usage and duty cycle per device: metrics [-discover]", runMetrics},
+	"top":       {"print the HBM usage and duty cycle of every process holding TPU chips", runTop},
+	"endpoints": {"list the runtime metrics server of every process holding TPU chips", runEndpoints},
+	"metric":    {"print every sample of a runtime metric: metric NAME", runMetric},
+	"catalog":   {"list the runtime metrics supported by libtpu: catalog [-filter REGEX]", runCatalog},
+	"latency":   {"print percentiles of the runtime transfer and collective latencies", runLatency},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: tpuinfo [flags] <command> [args]\n\ncommands:\n")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-10s %s\n", name, commands[name].help)
+	}
+	fmt.Fprintf(os.Stderr, "\nflags:\n")
+	flag.PrintDefaults()
+}
+
+func newTable() *tabwriter.Writer {
+	return tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+}
+
+func main() {
+	port := flag.Int("port", 0, "runtime metrics port (default LIBTPUINFO_GRPC_PORT or 8431)")
+	target := flag.String("target", "", "runtime metrics gRPC target, overrides -port")
+	timeout := flag.Duration("timeout", tpuinfo.DefaultTimeout, "deadline for each metrics fetch")
+	debug := flag.Bool("debug", false, "enable debug logging")
+	flag.Usage = usage
+	flag.Parse()
+	if flag.NArg() == 0 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[flag.Arg(0)]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "tpuinfo: unknown command %q\n", flag.Arg(0))
+		usage()
+		os.Exit(2)
+	}
+	if *debug {
+		tpuinfo.DebugEnabled = true
+	}
+
+	opts := []tpuinfo.Option{tpuinfo.WithPort(*port), tpuinfo.WithTimeout(*timeout)}
+	if *target != "" {
+		opts = append(opts, tpuinfo.WithTarget(*target))
+	}
+	client, err := tpuinfo.NewClient(opts...)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tpuinfo: %v\n", err)
+		os.Exit(1)
+	}
+	defer client.Close()
+
+	if err := cmd.run(context.Background(), client, flag.Args()[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "tpuinfo: %v\n", err)
+		client.Close()
+		os.Exit(1)
+	}
+}
+
+func runChips(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	chips, err := tpuinfo.GetLocalChipTypes()
+	if err != nil {
+		return err
+	}
+	if len(chips) == 0 {
+		fmt.Println("No TPU chips found")
+		return nil
+	}
+	// one row per chip type, in order of first appearance
+	var types []*tpuinfo.TpuChip
+	count := make(map[*tpuinfo.TpuChip]int)
+	for _, chip := range chips {
+		if count[chip] == 0 {
+			types = append(types, chip)
+		}
+		count[chip]++
+	}
+	w := newTable()
+	fmt.Fprintf(w, "TYPE\tCHIPS\tDEVICES PER CHIP\tTENSORCORES\tHBM (GiB)\n")
+	devices := 0
+	for _, chip := range types {
+		fmt.Fprintf(w, "%s\t%d\t%d\t%s\t%d\n", chip, count[chip], chip.Value.DevicesPerChip, tensorCores(chip), chip.Value.HBMGiB)
+		devices += count[chip] * chip.Value.DevicesPerChip
+	}
+	if len(types) > 1 {
+		fmt.Fprintf(w, "total\t%d\t\t\t\n", len(chips))
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Printf("%d devices\n", devices)
+	return nil
+}
+
+// tensorCores formats the TensorCores per chip, e.g. "2 (megacore)".
+func tensorCores(chip *tpuinfo.TpuChip) string {
+	if chip.Value.Megacore {
+		return fmt.Sprintf("%d (megacore)", chip.Value.TensorCores)
+	}
+	return fmt.Sprintf("%d", chip.Value.TensorCores)
+}
+
+func runTopology(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	if len(devices) == 0 {
+		fmt.Println("No TPU chips found")
+		return nil
+	}
+	topology, err := tpuinfo.NewTopology(devices)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%d %s chips in %s, %s TensorCores per chip\n\n", len(topology.Chips), topology.Chip,
+		joinInts(topology.Bounds[:], "x"), tensorCores(topology.Chip))
+	fmt.Println(topology.Diagram())
+	w := newTable()
+	fmt.Fprintf(w, "CHIP\tCOORDS\tDEV\tNEIGHBORS\n")
+	for _, chip := range topology.Chips {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", chip.Device.Index, chip.Coords, orDash(chip.Device.DevPath),
+			orDash(joinInts(chip.Neighbors, ",")))
+	}
+	return w.Flush()
+}
+
+func runDevices(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "CHIP\tTYPE\tPCI\tID\tDRIVER\tNUMA\tCPUS\tIOMMU\tDEV\n")
+	for _, d := range devices {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s:%s\t%s\t%s\t%s\t%s\t%s\n", d.Index, d.Chip, d.BDF, d.VendorID, d.DeviceID,
+			orDash(d.Driver), intOrDash(d.NUMANode), orDash(d.LocalCPUList), intOrDash(d.IOMMUGroup), d.DevPath)
+	}
+	return w.Flush()
+}
+
+func runIndex(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	mapping, err := client.DeviceIndex(ctx)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tpuinfo: runtime device ids unavailable: %v\n", err)
+		if mapping, err = tpuinfo.DeviceIndex(); err != nil {
+			return err
+		}
+	}
+	w := newTable()
+	fmt.Fprintf(w, "INDEX\tCHIP\tCORE\tPCI\tDEV\tRUNTIME ID\n")
+	for _, row := range mapping {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\t%s\t%s\n", row.Index, row.Chip, row.Core, row.BDF, orDash(row.DevPath), intOrDash(row.RuntimeDeviceID))
+	}
+	return w.Flush()
+}
+
+func runOwners(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	owners, err := tpuinfo.GetChipOwners()
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "CHIP\tDEV\tPID\tSTART\tFD\tMODE\tFLAGS\n")
+	for _, d := range devices {
+		if len(owners[d.Index]) == 0 {
+			fmt.Fprintf(w, "%d\t%s\t-\t-\t-\t-\t-\n", d.Index, orDash(d.DevPath))
+		}
+		for _, o := range owners[d.Index] {
+			for _, f := range o.Files {
+				fmt.Fprintf(w, "%d\t%s\t%d\t%d\t%d\t%s\t%#o\n", d.Index, d.DevPath, o.Pid, o.StartTicks, f.FD, f.AccessMode(), f.Flags)
+			}
+		}
+	}
+	return w.Flush()
+}
+
+func runProcesses(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	fs := flag.NewFlagSet("ps", flag.ExitOnError)
+	pidns := fs.Int64("pidns", 0, "also show pids as seen from the pid namespace of this process")
+	fs.Parse(args)
+	var view tpuinfo.PidNamespace
+	if *pidns > 0 {
+		var err error
+		if view, err = tpuinfo.PidNamespaceOf(*pidns); err != nil {
+			return err
+		}
+	}
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	procs, err := tpuinfo.GetChipProcesses()
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "CHIP\tDEV\tPID\t")
+	if view != 0 {
+		fmt.Fprintf(w, "NS PID\t")
+	}
+	fmt.Fprintf(w, "USER\tCPU%%\tRSS (GiB)\tTHREADS\tELAPSED\tCONTAINER\tJOB\tFRAMEWORK\tLIBTPU\tCOMMAND\n")
+	for _, d := range devices {
+		for _, p := range procs[d.Index] {
+			fmt.Fprintf(w, "%d\t%s\t%d\t", d.Index, d.DevPath, p.Pid)
+			if view != 0 {
+				ns_pid := "-"
+				if pid, ok := p.PidIn(view); ok {
+					ns_pid = fmt.Sprint(pid)
+				}
+				fmt.Fprintf(w, "%s\t", ns_pid)
+			}
+			fmt.Fprintf(w, "%s\t%.1f\t%.2f\t%d\t%s\t%s\t%s\t%s\t%s\t%s\n", orDash(p.User), p.CPUPct, gib(p.RSSBytes), p.Threads,
+				p.Elapsed.Truncate(time.Second), orDash(shortContainerID(p.ContainerID)), orDash(slurmJob(p)),
+				orDash(string(p.Framework)), orDash(libtpuVersion(p.LibTPU)), orDash(p.Command()))
+		}
+	}
+	return w.Flush()
+}
+
+func runPods(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	fs := flag.NewFlagSet("pods", flag.ExitOnError)
+	socket := fs.String("socket", tpuinfo.DefaultPodResourcesSocket, "kubelet PodResources API socket")
+	fs.Parse(args)
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	procs, err := tpuinfo.GetChipProcesses()
+	if err != nil {
+		return err
+	}
+	// the kubelet is optional: without it pods are identified by UID only
+	var allocs []tpuinfo.PodDevices
+	if _, err := os.Stat(*socket); err == nil {
+		if allocs, err = tpuinfo.ListPodResources(ctx, *socket); err != nil {
+			fmt.Fprintf(os.Stderr, "tpuinfo: %v\n", err)
+		}
+	}
+	pods := tpuinfo.ChipPods(devices, allocs)
+	w := newTable()
+	fmt.Fprintf(w, "CHIP\tDEV\tNAMESPACE\tPOD\tCONTAINER\tPID\tLABELS\n")
+	for _, d := range devices {
+		p := pods[d.Index]
+		if len(procs[d.Index]) == 0 {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t-\t-\n", d.Index, d.DevPath, orDash(p.Namespace), orDash(p.Pod), orDash(p.Container))
+		}
+		for _, proc := range procs[d.Index] {
+			fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\t%d\t%s\n", d.Index, d.DevPath, orDash(p.Namespace), orDash(p.Pod), orDash(p.Container),
+				proc.Pid, orDash(proc.Labels().String()))
+		}
+	}
+	return w.Flush()
+}
+
+// libtpuVersion formats the libtpu of a process as its version, or its
+// abbreviated build id if the version is unknown.
+func libtpuVersion(lib *tpuinfo.LibTPU) string {
+	switch {
+	case lib == nil:
+		return ""
+	case lib.Version != "":
+		return lib.Version
+	case len(lib.BuildID) > 12:
+		return "build " + lib.BuildID[:12]
+	}
+	return "unknown"
+}
+
+// slurmJob formats the Slurm job step of a process as "job.step".
+func slurmJob(p *tpuinfo.Process) string {
+	if p.SlurmStepID == "" {
+		return p.SlurmJobID
+	}
+	return p.SlurmJobID + "." + p.SlurmStepID
+}
+
+func runGres(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("# TPU chips of this host, generated by tpuinfo gres\n%s", tpuinfo.GresConf(devices))
+	return nil
+}
+
+// shortContainerID abbreviates a container id to 12 characters, as docker does.
+func shortContainerID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func intOrDash(v int) string {
+	if v < 0 {
+		return "-"
+	}
+	return fmt.Sprint(v)
+}
+
+func runMetrics(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	fs := flag.NewFlagSet("metrics", flag.ExitOnError)
+	discover := fs.Bool("discover", false, "query the metrics server of every process holding TPU chips and merge by device index")
+	fs.Parse(args)
+	var metrics *tpuinfo.Metrics
+	var err error
+	if *discover {
+		metrics, err = tpuinfo.GetDiscoveredMetrics(ctx)
+		if err != nil && metrics != nil {
+			// some endpoints answered
+			fmt.Fprintf(os.Stderr, "tpuinfo: %v\n", err)
+			err = nil
+		}
+	} else {
+		metrics, err = client.DeviceMetrics(ctx)
+		if errors.Is(err, tpuinfo.ErrDeviceIndexMismatch) {
+			return fmt.Errorf("%w; use metrics -discover if several processes share the chips", err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "DEVICE\tHBM USAGE (GiB)\tHBM TOTAL (GiB)\tDUTY CYCLE (%%)\n")
+	for i, id := range metrics.DeviceIDs {
+		fmt.Fprintf(w, "%d\t%.2f\t%.2f\t%.2f\n", id, gib(metrics.MemoryUsage[i]), gib(metrics.TotalMemory[i]), metrics.DutyCyclePct[i])
+	}
+	return w.Flush()
+}
+
+func runTop(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	usage, err := tpuinfo.GetProcessUsage(ctx)
+	if err != nil && usage == nil {
+		return err
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "tpuinfo: %v\n", err)
+	}
+	w := newTable()
+	fmt.Fprintf(w, "PID\tUSER\tCONTAINER\tCHIPS\tHBM USAGE (GiB)\tHBM TOTAL (GiB)\tDUTY CYCLE (%%)\tCOMMAND\n")
+	for _, u := range usage {
+		chips := joinInts(u.Chips, ",")
+		if u.Shared {
+			chips += " (shared)"
+		}
+		p := u.Process
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%.2f\t%.2f\t%.2f\t%s\n", p.Pid, orDash(p.User), orDash(shortContainerID(p.ContainerID)), chips,
+			gib(u.MemoryUsage), gib(u.TotalMemory), u.DutyCyclePct, orDash(p.Command()))
+	}
+	return w.Flush()
+}
+
+func runEndpoints(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	endpoints, err := tpuinfo.DiscoverEndpoints()
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "TARGET\tPID\tCHIPS\tVISIBLE CHIPS\tCHIPS/PROCESS\tPROCESSES\tLISTENING\n")
+	for _, e := range endpoints {
+		fmt.Fprintf(w, "%s\t%d\t%s\t%s\t%s\t%s\t%t\n", e.Target, e.Owner.Pid, joinInts(e.Chips, ","), orDash(joinInts(e.Bounds.VisibleChips, ",")),
+			orDash(joinInts(e.Bounds.ChipsPerProcessBounds, "x")), orDash(joinInts(e.Bounds.ProcessBounds, "x")), e.Listening)
+	}
+	return w.Flush()
+}
+
+func joinInts(ints []int, sep string) string {
+	s := make([]string, len(ints))
+	for i, v := range ints {
+		s[i] = fmt.Sprint(v)
+	}
+	return strings.Join(s, sep)
+}
+
+func gib(bytes int64) float64 {
+	return float64(bytes) / float64(1<<30)
+}
+
+func runMetric(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: tpuinfo metric NAME")
+	}
+	metric, err := client.MetricByName(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	if metric.Description != "" {
+		fmt.Printf("%s: %s\n", metric.Name, metric.Description)
+	}
+	w := newTable()
+	fmt.Fprintf(w, "LABELS\tMEASURE\tKIND\tVALUE\tTIMESTAMP\n")
+	for _, s := range metric.Samples {
+		ts := "-"
+		if !s.Timestamp.IsZero() {
+			ts = s.Timestamp.Local().Format(time.RFC3339)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", s.LabelString(), s.Measure, s.Value.Kind, s.Value, ts)
+	}
+	return w.Flush()
+}
+
+func runCatalog(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	fs := flag.NewFlagSet("catalog", flag.ExitOnError)
+	filter := fs.String("filter", "", "regex the metric names must match")
+	fs.Parse(args)
+	catalog, err := client.Catalog(ctx, *filter)
+	if err != nil {
+		return err
+	}
+	w := newTable()
+	fmt.Fprintf(w, "NAME\tMEASURE\tKIND\tDESCRIPTION\n")
+	for _, d := range catalog {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", d.Name, d.Measure, d.Kind, d.Description)
+	}
+	return w.Flush()
+}
+
+func runLatency(ctx context.Context, client *tpuinfo.Client, args []string) error {
+	latencies, err := client.LatencyHistograms(ctx)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(latencies))
+	for name := range latencies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	w := newTable()
+	fmt.Fprintf(w, "LATENCY (us)\tLABELS\tCOUNT\tMEAN\tP50\tP90\tP99\tSTDDEV\tMIN\tMAX\n")
+	for _, name := range names {
+		for _, s := range latencies[name].Samples {
+			h := s.Histogram
+			if h == nil {
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\t%.1f\n", name, s.LabelString(),
+				h.Count, h.Mean, h.P50(), h.P90(), h.P99(), h.StdDev(), h.Min, h.Max)
+		}
+	}
+	return w.Flush()
+}
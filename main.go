@@ -1,343 +1,520 @@
 package main
 
+/*
+#define TPU_STRING_LEN 128
+
+// value kinds, matching tpuinfo.ValueKind
+#define TPU_KIND_UNKNOWN 0
+#define TPU_KIND_DOUBLE 1
+#define TPU_KIND_INT 2
+#define TPU_KIND_STRING 3
+#define TPU_KIND_BOOL 4
+#define TPU_KIND_UINT 5
+
+typedef struct {
+	long long device_id;   // integer attribute of the sample, -1 if none
+	int kind;              // TPU_KIND_*
+	double as_double;      // set for every numeric kind
+	long long as_int;      // TPU_KIND_INT, TPU_KIND_UINT, TPU_KIND_BOOL (0/1)
+	char as_string[TPU_STRING_LEN];  // value formatted as a string
+	long long timestamp_ns;          // unix nanoseconds, 0 if unset
+	char labels[TPU_STRING_LEN];     // "key=value,key2=value2"
+} tpu_metric_sample;
+
+typedef struct {
+	char name[16];   // chip type, e.g. "v5e"
+	int chips;       // number of chips of this type
+	int devices;     // chips * devices per chip
+	int hbm_gib;     // HBM per chip
+	int tensor_cores;  // TensorCores per chip
+	int megacore;      // 1 if the TensorCores of a chip form one device
+} tpu_chip_type_count;
+
+typedef struct {
+	int index;                  // position in PCI address order
+	char bdf[16];               // PCI address, e.g. "0000:00:04.0"
+	char vendor_id[8];          // e.g. "0x1ae0"
+	char device_id[8];
+	char subsystem_vendor_id[8];
+	char subsystem_id[8];
+	char chip_type[16];         // e.g. "v5e"
+	char driver[32];            // bound kernel driver, "" if none
+	int numa_node;              // -1 if unknown
+	char local_cpulist[TPU_STRING_LEN];
+	int iommu_group;            // -1 if none
+	char dev_path[64];          // /dev node of the chip
+} tpu_device;
+
+typedef struct {
+	int index;                  // canonical device index
+	int chip;                   // tpu_device.index of the chip
+	int core;                   // core within the chip
+	char bdf[16];
+	char dev_path[64];
+	long long runtime_device_id;  // -1 if unknown
+} tpu_device_mapping;
+
+typedef struct {
+	int chip;         // tpu_device.index
+	int x, y, z;      // coordinates in the host bounds
+	int neighbors[6]; // tpu_device.index of the ICI neighbours, ascending
+	int neighbor_count;
+} tpu_topology_chip;
+
+typedef struct {
+	int chip;         // tpu_device.index of the held chip
+	long long pid;
+	unsigned long long start_ticks;  // process start time, see tpu_process_verify
+	int fd;
+	int flags;        // open(2) flags from /proc/<pid>/fdinfo, -1 if unknown
+} tpu_owner;
+
+typedef struct {
+	int chip;                  // tpu_device.index of the held chip
+	long long pid;
+	unsigned long long start_ticks;  // process start time, see tpu_process_verify
+	int uid;                   // -1 if unknown
+	char user[32];
+	char exe[TPU_STRING_LEN];
+	char cmdline[2 * TPU_STRING_LEN];  // arguments joined by spaces
+	long long start_time_ns;   // unix nanoseconds, 0 if unknown
+	long long elapsed_ms;
+	double cpu_pct;            // since the previous call, 100 is one core
+	long long rss_bytes;
+	int threads;
+	long long ns_pid;          // pid in the tpu_set_pid_namespace view, -1 if not visible
+	long long inner_pid;       // pid the process sees for itself, innermost NSpid
+	char container_id[72];     // "" outside containers
+	char container_runtime[16];  // "docker", "containerd", "cri-o", "podman" or ""
+	char framework[16];        // "jax", "pytorch-xla", "tensorflow" or "" if unknown
+	char libtpu_path[TPU_STRING_LEN];  // "" if the process did not map libtpu
+	char libtpu_version[32];
+	char libtpu_build_id[48];  // hex GNU build id
+} tpu_process;
+
+typedef struct {
+	long long pid;
+	unsigned long long start_ticks;  // process start time, see tpu_process_verify
+	int uid;                   // -1 if unknown
+	char user[32];
+	char container_id[72];     // "" outside containers
+	int chips;                 // number of chips the process holds
+	int devices;               // number of those chips' devices with metrics
+	long long memory_usage;    // HBM bytes, summed over the devices
+	long long total_memory;
+	double duty_cycle_pct;     // mean over the devices
+	int shared;                // 1 if another process holds one of the chips
+} tpu_process_usage;
+
+typedef struct {
+	char name[TPU_STRING_LEN];
+	char description[2 * TPU_STRING_LEN];
+	int measure;  // 0 unknown, 1 gauge, 2 counter, 3 distribution, 4 summary
+	int kind;     // TPU_KIND_*
+} tpu_metric_info;
+*/
+import "C"
+
 import (
-	"C"
 	"context"
-	"fmt"
-	"io/ioutil"
-	"log"
-	"os"
-	"path/filepath"
-	"regexp"
+	"errors"
 	"sort"
-	"strconv"
+	"sync/atomic"
 	"time"
 	"unsafe"
 
-	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
-	"google.golang.org/grpc"
-	"google.golang.org/grpc/credentials/insecure"
-)
-
-const (
-	TOTAL_MEMORY   = "tpu.runtime.hbm.memory.total.bytes"
-	MEMORY_USAGE   = "tpu.runtime.hbm.memory.usage.bytes"
-	DUTY_CYCLE_PCT = "tpu.runtime.tensorcore.dutycycle.percent"
-)
-
-const (
-	googlePCIVendorID = "0x1ae0"
-)
-
-var (
-	DebugEnabled bool        = os.Getenv("LIBTPUINFO_DEBUG") == "1"
-	logger       *log.Logger = nil
+	"github.com/rdyro/libtpuinfo/tpuinfo"
+	"golang.org/x/sys/unix"
 )
 
 func debugLogf(format string, args ...interface{}) {
-	if logger == nil {
-		logger = log.New(os.Stderr, "[libtpuinfo]: ", log.LstdFlags)
-	}
-	if DebugEnabled {
-		logger.Printf(format, args...)
-	}
+	tpuinfo.Debugf(format, args...)
 }
 
-var defaultGRPCPort int = parse_defaultGRPCPort()
-
-func parse_defaultGRPCPort() int {
-	// if not set, attempt to parse the environment variable
-	defaultGRPCPort := 8431
-	if env_port, is_set := os.LookupEnv("LIBTPUINFO_GRPC_PORT"); is_set {
-		debugLogf("Found environment variable LIBTPUINFO_GRPC_PORT: %s\n", env_port)
-		if val, err := strconv.ParseInt(env_port, 10, 64); err == nil {
-			debugLogf("Parsed environment variable LIBTPUINFO_GRPC_PORT as int\n")
-			defaultGRPCPort = int(val)
-		}
+func copyValuesToC[T1 any, T2 any](c_array_ *T1, go_array []T2, convertFn func(T2) T1) {
+	c_array := (*[1 << 30]T1)(unsafe.Pointer(c_array_))[:len(go_array):len(go_array)]
+	for i, v := range go_array {
+		c_array[i] = convertFn(v)
 	}
-	return defaultGRPCPort
 }
 
-type TpuChipInfo struct {
-	Name           string
-	HBMGiB         int
-	DevicesPerChip int
+// copyStringToC copies s into the NUL-terminated buffer dst of size n,
+// truncating if needed.
+func copyStringToC(dst *C.char, n int, s string) {
+	if n <= 0 {
+		return
+	}
+	buf := unsafe.Slice((*byte)(unsafe.Pointer(dst)), n)
+	k := copy(buf[:n-1], s)
+	buf[k] = 0
 }
 
-type TpuChip struct {
-	Value TpuChipInfo
+//export tpu_chip_count
+func tpu_chip_count() C.int {
+	count, err := tpuinfo.DeviceCount()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return 0
+	}
+	return C.int(count)
 }
 
-var (
-	V2  = TpuChip{Value: TpuChipInfo{Name: "v2", HBMGiB: 8, DevicesPerChip: 2}}
-	V3  = TpuChip{Value: TpuChipInfo{Name: "v3", HBMGiB: 16, DevicesPerChip: 2}}
-	V4  = TpuChip{Value: TpuChipInfo{Name: "v4", HBMGiB: 32, DevicesPerChip: 1}}
-	V5E = TpuChip{Value: TpuChipInfo{Name: "v5e", HBMGiB: 16, DevicesPerChip: 1}}
-	V5P = TpuChip{Value: TpuChipInfo{Name: "v5p", HBMGiB: 95, DevicesPerChip: 1}}
-	V6E = TpuChip{Value: TpuChipInfo{Name: "v6e", HBMGiB: 32, DevicesPerChip: 1}}
-)
+//export tpu_chip_types
+func tpu_chip_types(types *C.tpu_chip_type_count, n C.int, count *C.int) C.int {
+	chips, err := tpuinfo.GetLocalChipTypes()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return 1
+	}
+	// one entry per chip type, in order of first appearance
+	per_type := make([]C.tpu_chip_type_count, 0)
+	index := make(map[string]int)
+	for _, chip := range chips {
+		i, ok := index[chip.Value.Name]
+		if !ok {
+			i = len(per_type)
+			index[chip.Value.Name] = i
+			per_type = append(per_type, C.tpu_chip_type_count{
+				hbm_gib:      C.int(chip.Value.HBMGiB),
+				tensor_cores: C.int(chip.Value.TensorCores),
+			})
+			if chip.Value.Megacore {
+				per_type[i].megacore = 1
+			}
+			copyStringToC(&per_type[i].name[0], len(per_type[i].name), chip.Value.Name)
+		}
+		per_type[i].chips++
+		per_type[i].devices += C.int(chip.Value.DevicesPerChip)
+	}
+	if count != nil {
+		*count = C.int(len(per_type))
+	}
+	m := min(int(n), len(per_type))
+	if m <= 0 {
+		return 0
+	}
+	copy(unsafe.Slice(types, m), per_type)
+	return 0
+}
 
-func (t TpuChip) String() string {
-	return t.Value.Name
+//export tpu_devices
+func tpu_devices(devices *C.tpu_device, n C.int, count *C.int) C.int {
+	devices_go, err := tpuinfo.ListDevices()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return 1
+	}
+	if count != nil {
+		*count = C.int(len(devices_go))
+	}
+	m := min(int(n), len(devices_go))
+	if m <= 0 {
+		return 0
+	}
+	out := unsafe.Slice(devices, m)
+	for i, d := range devices_go[:m] {
+		out[i].index = C.int(d.Index)
+		copyStringToC(&out[i].bdf[0], len(out[i].bdf), d.BDF)
+		copyStringToC(&out[i].vendor_id[0], len(out[i].vendor_id), d.VendorID)
+		copyStringToC(&out[i].device_id[0], len(out[i].device_id), d.DeviceID)
+		copyStringToC(&out[i].subsystem_vendor_id[0], len(out[i].subsystem_vendor_id), d.SubsystemVendorID)
+		copyStringToC(&out[i].subsystem_id[0], len(out[i].subsystem_id), d.SubsystemID)
+		copyStringToC(&out[i].chip_type[0], len(out[i].chip_type), d.Chip.Value.Name)
+		copyStringToC(&out[i].driver[0], len(out[i].driver), d.Driver)
+		out[i].numa_node = C.int(d.NUMANode)
+		copyStringToC(&out[i].local_cpulist[0], len(out[i].local_cpulist), d.LocalCPUList)
+		out[i].iommu_group = C.int(d.IOMMUGroup)
+		copyStringToC(&out[i].dev_path[0], len(out[i].dev_path), d.DevPath)
+	}
+	return 0
 }
 
-func fromPCIDeviceID(deviceID, subsystemID string) *TpuChip {
-	// TPU v2 and v3 share a device ID
-	if deviceID == "0x0027" {
-		if subsystemID == "0x004e" {
-			return &V2
-		} else if subsystemID == "0x004f" {
-			return &V3
+//export tpu_topology
+func tpu_topology(chips *C.tpu_topology_chip, n C.int, bounds *C.int, count *C.int) C.int {
+	topology, err := tpuinfo.GetTopology()
+	if err != nil {
+		debugLogf("Could not build the TPU topology: %v\n", err)
+		return 1
+	}
+	if bounds != nil {
+		out := unsafe.Slice(bounds, 3)
+		for i, b := range topology.Bounds {
+			out[i] = C.int(b)
 		}
 	}
-
-	deviceIDToDevice := map[string]*TpuChip{
-		"0x005e": &V4,
-		"0x0063": &V5E,
-		"0x0062": &V5P,
-		"0x006f": &V6E,
+	if count != nil {
+		*count = C.int(len(topology.Chips))
 	}
-
-	if chip, ok := deviceIDToDevice[deviceID]; ok {
-		return chip
+	m := min(int(n), len(topology.Chips))
+	if m <= 0 {
+		return 0
 	}
-	return nil
+	out := unsafe.Slice(chips, m)
+	for i, chip := range topology.Chips[:m] {
+		out[i].chip = C.int(chip.Device.Index)
+		out[i].x = C.int(chip.Coords.X)
+		out[i].y = C.int(chip.Coords.Y)
+		out[i].z = C.int(chip.Coords.Z)
+		out[i].neighbor_count = C.int(len(chip.Neighbors))
+		for j, neighbor := range chip.Neighbors {
+			out[i].neighbors[j] = C.int(neighbor)
+		}
+	}
+	return 0
 }
 
-// caching chip discovery
-var (
-	tpu_chip       (*TpuChip) = nil
-	chip_count     int        = -1
-	last_refreshed            = time.Now()
-)
-
-const cache_duration = 3 * time.Second
-
-func isCacheValid() bool {
-	return chip_count >= 0 && last_refreshed.After(time.Now().Add(-cache_duration))
+//export tpu_device_index
+func tpu_device_index(port C.int, rows *C.tpu_device_mapping, n C.int, count *C.int) C.int {
+	mapping, err := tpuinfo.DeviceIndex()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return 1
+	}
+	// runtime device ids are best effort, -1 when the metrics server is down
+	if client, err := tpuinfo.SharedClient(tpuinfo.WithPort(int(port))); err == nil {
+		if with_ids, err := client.DeviceIndex(context.Background()); err == nil {
+			mapping = with_ids
+		} else {
+			debugLogf("Could not get runtime device ids: %v\n", err)
+		}
+	}
+	if count != nil {
+		*count = C.int(len(mapping))
+	}
+	m := min(int(n), len(mapping))
+	if m <= 0 {
+		return 0
+	}
+	out := unsafe.Slice(rows, m)
+	for i, row := range mapping[:m] {
+		out[i].index = C.int(row.Index)
+		out[i].chip = C.int(row.Chip)
+		out[i].core = C.int(row.Core)
+		copyStringToC(&out[i].bdf[0], len(out[i].bdf), row.BDF)
+		copyStringToC(&out[i].dev_path[0], len(out[i].dev_path), row.DevPath)
+		out[i].runtime_device_id = C.longlong(row.RuntimeDeviceID)
+	}
+	return 0
 }
 
-func updateCache(chip *TpuChip, count int) {
-	tpu_chip = chip
-	chip_count = count
-	last_refreshed = time.Now()
+//export tpu_pids
+func tpu_pids(pids *C.longlong, n C.int) C.int {
+	return tpu_pids_busy(pids, n, nil)
 }
 
-func getLocalChips() (*TpuChip, int) {
-	if isCacheValid() {
-		return tpu_chip, chip_count
+//export tpu_pids_busy
+func tpu_pids_busy(pids *C.longlong, n C.int, busy *C.int) C.int {
+	pids_go, busy_go, err := tpuinfo.GetDevicePids()
+	if err != nil {
+		debugLogf("Could not find TPU processes: %v\n", err)
+		return 2
 	}
-	cacheAndReturn := func(t *TpuChip, num int) (*TpuChip, int) {
-		updateCache(t, num)
-		return t, num
+	if len(pids_go) != int(n) {
+		debugLogf("Requested PIDs for %d TPU devices, but only %d found\n", n, len(pids_go))
+		return 1
+	}
+	// unowned devices are reported as -1
+	out := unsafe.Slice(pids, n)
+	for i, pid := range pids_go {
+		out[i] = C.longlong(pid)
 	}
+	// counted from the same scan as the pids
+	if busy != nil {
+		*busy = C.int(busy_go)
+	}
+	return 0
+}
 
-	count := make(map[string]int)
-	files, err := filepath.Glob("/sys/bus/pci/devices/*")
+//export tpu_busy_count
+func tpu_busy_count() C.int {
+	_, busy, err := tpuinfo.GetDevicePids()
 	if err != nil {
-		return cacheAndReturn(nil, 0)
+		debugLogf("Could not find TPU processes: %v\n", err)
+		return -1
 	}
+	return C.int(busy)
+}
 
-	for _, pciPath := range files {
-		vendorPath := filepath.Join(pciPath, "vendor")
-		vendorIDBytes, err := ioutil.ReadFile(vendorPath)
-		if err != nil {
-			continue // Skip this device if we can't read the vendor ID
-		}
-		vendorID := string(vendorIDBytes[:len(vendorIDBytes)-1]) //remove newline
-		if vendorID != googlePCIVendorID {
-			continue
-		}
-		deviceIDPath := filepath.Join(pciPath, "device")
-		deviceIDBytes, err := ioutil.ReadFile(deviceIDPath)
-		if err != nil {
-			continue // Skip this device
-		}
-		deviceID := string(deviceIDBytes[:len(deviceIDBytes)-1]) //remove newline
-
-		subsystemPath := filepath.Join(pciPath, "subsystem_device")
-		subsystemIDBytes, err := ioutil.ReadFile(subsystemPath)
-		if err != nil {
-			continue // Skip
-		}
-		subsystemID := string(subsystemIDBytes[:len(subsystemIDBytes)-1]) //remove newline
-
-		chipType := fromPCIDeviceID(deviceID, subsystemID)
-		if chipType != nil {
-			count[chipType.Value.Name]++ //count by name instead of by *TpuChip
-		}
+//export tpu_free_chip_count
+func tpu_free_chip_count() C.int {
+	devices, err := tpuinfo.ListDevices()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return -1
 	}
-
-	if len(count) > 1 {
-		panic(fmt.Sprintf("Expected one chip type, got %v", count))
-	}
-	if len(count) == 0 {
-		return cacheAndReturn(nil, 0)
-	}
-
-	//find the only entry in count
-	for name, num := range count {
-		switch name {
-		case "v2":
-			return cacheAndReturn(&V2, num)
-		case "v3":
-			return cacheAndReturn(&V3, num)
-		case "v4":
-			return cacheAndReturn(&V4, num)
-		case "v5e":
-			return cacheAndReturn(&V5E, num)
-		case "v5p":
-			return cacheAndReturn(&V5P, num)
-		case "v6e":
-			return cacheAndReturn(&V6E, num)
+	owners, err := tpuinfo.GetChipOwners()
+	if err != nil {
+		debugLogf("Could not find TPU processes: %v\n", err)
+		return -1
+	}
+	free := 0
+	for _, d := range devices {
+		if _, ok := owners[d.Index]; !ok {
+			free++
 		}
 	}
-	return cacheAndReturn(nil, 0)
+	return C.int(free)
 }
 
-func chipPath(chipType TpuChip, index int) string {
-	if chipType == V5E || chipType == V5P || chipType == V6E {
-		return fmt.Sprintf("/dev/vfio/%d", index)
-	} else {
-		return fmt.Sprintf("/dev/accel%d", index)
+//export tpu_owners
+func tpu_owners(owners *C.tpu_owner, n C.int, count *C.int) C.int {
+	chip_owners, err := tpuinfo.GetChipOwners()
+	if err != nil {
+		debugLogf("Could not find TPU processes: %v\n", err)
+		return 2
 	}
+	// one row per chip, process and descriptor, in chip then pid order
+	chips := make([]int, 0, len(chip_owners))
+	for chip := range chip_owners {
+		chips = append(chips, chip)
+	}
+	sort.Ints(chips)
+	rows := make([]C.tpu_owner, 0)
+	for _, chip := range chips {
+		for _, o := range chip_owners[chip] {
+			for _, f := range o.Files {
+				rows = append(rows, C.tpu_owner{
+					chip:        C.int(chip),
+					pid:         C.longlong(o.Pid),
+					start_ticks: C.ulonglong(o.StartTicks),
+					fd:          C.int(f.FD),
+					flags:       C.int(f.Flags),
+				})
+			}
+		}
+	}
+	if count != nil {
+		*count = C.int(len(rows))
+	}
+	m := min(int(n), len(rows))
+	if m <= 0 {
+		return 0
+	}
+	copy(unsafe.Slice(owners, m), rows)
+	return 0
 }
 
-var (
-	tpuDeviceRegex = regexp.MustCompile(`^/dev/(?:accel|vfio/)\d+$`)
-	procFDPidRegex = regexp.MustCompile(`/proc/(\d+)/fd/\d+`)
-)
-
-func getChipProcessOwners() (map[string]int64, error) {
-	deviceOwners := make(map[string]int64)
+// pid_view is the pid namespace tpu_process.ns_pid is reported in, zero for
+// the namespace of the calling process. C callers may set and read it from
+// different threads.
+var pid_view atomic.Uint64
 
-	procDir := "/proc"
-	pids, err := os.ReadDir(procDir)
+//export tpu_set_pid_namespace
+func tpu_set_pid_namespace(pid C.longlong) C.int {
+	if pid <= 0 {
+		pid_view.Store(0)
+		return 0
+	}
+	ns, err := tpuinfo.PidNamespaceOf(int64(pid))
 	if err != nil {
-		return nil, fmt.Errorf("failed to read /proc directory: %w", err)
+		debugLogf("Could not read the pid namespace of process %d: %v\n", pid, err)
+		return 1
 	}
-	for _, pidEntry := range pids {
-		if !pidEntry.IsDir() {
-			continue
-		}
+	pid_view.Store(uint64(ns))
+	return 0
+}
 
-		pidStr := pidEntry.Name()
-		pid, err := strconv.ParseInt(pidStr, 10, 64)
-		if err != nil {
-			continue // Not a PID directory, skip
-		}
-		fdDir := filepath.Join(procDir, pidStr, "fd")
-		fdEntries, err := os.ReadDir(fdDir)
-		if err != nil {
-			// Process might have terminated or we don't have permissions to read /proc/<pid>/fd.
-			if os.IsNotExist(err) || os.IsPermission(err) {
-				continue // Skip to the next PID
+//export tpu_processes
+func tpu_processes(procs *C.tpu_process, n C.int, count *C.int) C.int {
+	chip_procs, err := tpuinfo.GetChipProcesses()
+	if err != nil {
+		debugLogf("Could not find TPU processes: %v\n", err)
+		return 2
+	}
+	// one row per chip and process, in chip then pid order
+	chips := make([]int, 0, len(chip_procs))
+	for chip := range chip_procs {
+		chips = append(chips, chip)
+	}
+	sort.Ints(chips)
+	view := tpuinfo.PidNamespace(pid_view.Load())
+	rows := make([]C.tpu_process, 0)
+	for _, chip := range chips {
+		for _, p := range chip_procs[chip] {
+			row := C.tpu_process{
+				chip:        C.int(chip),
+				pid:         C.longlong(p.Pid),
+				start_ticks: C.ulonglong(p.StartTicks),
+				uid:         C.int(p.UID),
+				elapsed_ms:  C.longlong(p.Elapsed.Milliseconds()),
+				cpu_pct:     C.double(p.CPUPct),
+				rss_bytes:   C.longlong(p.RSSBytes),
+				threads:     C.int(p.Threads),
 			}
-			return nil, fmt.Errorf("failed to read %s: %w", fdDir, err)
-		}
-
-		for _, fdEntry := range fdEntries {
-			fdNumStr := fdEntry.Name()
-			_, err := strconv.ParseInt(fdNumStr, 10, 64)
-			if err != nil {
-				continue // Not a file descriptor number, skip. Shouldn't really happen, but handle it.
+			row.ns_pid = -1
+			if pid, ok := p.PidIn(view); ok {
+				row.ns_pid = C.longlong(pid)
+			}
+			row.inner_pid = C.longlong(p.Pid)
+			if len(p.NSpids) > 0 {
+				row.inner_pid = C.longlong(p.NSpids[len(p.NSpids)-1])
 			}
-			fdLink := filepath.Join(fdDir, fdNumStr)
-			file, err := os.Readlink(fdLink)
-			if err != nil {
-				// FileNotFoundError is expected if a process closes a file descriptor
-				// while we're iterating.  Just ignore it.  Other errors are unexpected.
-				if os.IsNotExist(err) {
-					continue
-				}
-				return nil, fmt.Errorf("readlink failed for %s: %w", fdLink, err)
+			if !p.StartTime.IsZero() {
+				row.start_time_ns = C.longlong(p.StartTime.UnixNano())
 			}
-			matched := tpuDeviceRegex.MatchString(file)
-			if !matched {
-				continue
+			copyStringToC(&row.user[0], len(row.user), p.User)
+			copyStringToC(&row.exe[0], len(row.exe), p.Exe)
+			copyStringToC(&row.cmdline[0], len(row.cmdline), p.Command())
+			copyStringToC(&row.container_id[0], len(row.container_id), p.ContainerID)
+			copyStringToC(&row.container_runtime[0], len(row.container_runtime), p.ContainerRuntime)
+			copyStringToC(&row.framework[0], len(row.framework), string(p.Framework))
+			if p.LibTPU != nil {
+				copyStringToC(&row.libtpu_path[0], len(row.libtpu_path), p.LibTPU.Path)
+				copyStringToC(&row.libtpu_version[0], len(row.libtpu_version), p.LibTPU.Version)
+				copyStringToC(&row.libtpu_build_id[0], len(row.libtpu_build_id), p.LibTPU.BuildID)
 			}
-			deviceOwners[file] = pid
+			rows = append(rows, row)
 		}
 	}
-	return deviceOwners, nil
-}
-
-func getSortedMetrics[T any](r *pb.MetricResponse, get_value func(g *pb.Gauge) T) ([]int, []T) {
-	metrics := r.GetMetric().GetMetrics()
-	metric_map := make(map[int]T)
-	device_ids := make([]int, 0)
-	for _, m := range metrics {
-		key := m.GetAttribute().GetValue().GetIntAttr()
-		val := get_value(m.GetGauge())
-		device_ids = append(device_ids, int(key))
-		metric_map[int(key)] = val
-	}
-	sort.Ints(device_ids)
-	metric_list := make([]T, len(metrics))
-	for i, k := range device_ids {
-		metric_list[i] = metric_map[k]
-	}
-	return device_ids, metric_list
-}
-
-func copyValuesToC[T1 any, T2 any](c_array_ *T1, go_array []T2, convertFn func(T2) T1) {
-	c_array := (*[1 << 30]T1)(unsafe.Pointer(c_array_))[:len(go_array):len(go_array)]
-	for i, v := range go_array {
-		c_array[i] = convertFn(v)
+	if count != nil {
+		*count = C.int(len(rows))
 	}
-}
-
-//export tpu_chip_count
-func tpu_chip_count() C.int {
-	chip_type, count := getLocalChips()
-	if chip_type != nil {
-		count = count * (*chip_type).Value.DevicesPerChip
+	m := min(int(n), len(rows))
+	if m <= 0 {
+		return 0
 	}
-	return C.int(count)
+	copy(unsafe.Slice(procs, m), rows)
+	return 0
 }
 
-//export tpu_pids
-func tpu_pids(pids *C.longlong, n C.int) C.int {
-	chip_type, count := getLocalChips()
-	devices_per_chip := 1
-	if chip_type != nil {
-		devices_per_chip = (*chip_type).Value.DevicesPerChip
-		count = count * devices_per_chip
-	}
-	if count != int(n) {
-		debugLogf("Requested PIDs for %d TPU chips, but only %d found\n", n, count)
-		return 1
+//export tpu_process_verify
+func tpu_process_verify(pid C.longlong, start_ticks C.ulonglong) C.int {
+	id := tpuinfo.ProcessID{Pid: int64(pid), StartTicks: uint64(start_ticks)}
+	ok, err := id.Verify()
+	if err != nil {
+		debugLogf("Could not verify process %v: %v\n", id, err)
+		return -1
 	}
-	chip_owners, err := getChipProcessOwners()
-	if err != nil || len(chip_owners)*devices_per_chip != int(n) {
-		debugLogf("Could not find TPU processes.")
-		if err != nil {
-			debugLogf(" %v\n", err)
-		} else {
-			debugLogf(" Asked for %d chips, but found %d processes\n", int(n), len(chip_owners))
-		}
-		return 2
+	if !ok {
+		return 0
 	}
-	pids_go := (*[1 << 30]C.longlong)(unsafe.Pointer(pids))[:count:count]
-	chip_paths := make([]string, 0)
-	for path, _ := range chip_owners {
-		chip_paths = append(chip_paths, path)
+	return 1
+}
+
+//export tpu_process_pidfd
+func tpu_process_pidfd(pid C.longlong, start_ticks C.ulonglong) C.int {
+	id := tpuinfo.ProcessID{Pid: int64(pid), StartTicks: uint64(start_ticks)}
+	f, err := id.Open()
+	if err != nil {
+		debugLogf("Could not open a pidfd for process %v: %v\n", id, err)
+		return -1
 	}
-	sort.Strings(chip_paths)
-	for i, path := range chip_paths {
-		for j := 0; j < devices_per_chip; j++ {
-			pids_go[devices_per_chip*i+j] = C.longlong(chip_owners[path])
-		}
+	// hand the descriptor over to the caller, who closes it
+	fd, err := unix.FcntlInt(f.Fd(), unix.F_DUPFD_CLOEXEC, 0)
+	f.Close()
+	if err != nil {
+		debugLogf("Could not open a pidfd for process %v: %v\n", id, err)
+		return -1
 	}
-	return 0
+	return C.int(fd)
 }
 
 //export tpu_metrics
 func tpu_metrics(port C.int, device_ids_ *C.longlong, memory_usage_ *C.longlong, total_memory_ *C.longlong, duty_cycle_pct_ *C.double, n C.int) C.int {
-
-	chip_type, count := getLocalChips()
-	if chip_type != nil {
-		count = count * (*chip_type).Value.DevicesPerChip
+	count, err := tpuinfo.DeviceCount()
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return 1
 	}
 	if count != int(n) {
 		debugLogf("Requested metrics for %d TPU chips, but only %d found\n", n, count)
@@ -346,158 +523,213 @@ func tpu_metrics(port C.int, device_ids_ *C.longlong, memory_usage_ *C.longlong,
 	if int(n) == 0 {
 		return 0
 	}
-	if port <= 0 {
-		port = C.int(defaultGRPCPort)
-	}
-	addr := fmt.Sprintf("localhost:%d", port)
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	client, err := tpuinfo.SharedClient(tpuinfo.WithPort(int(port)))
 	if err != nil {
 		debugLogf("Could not connect to the TPU metrics GRPC server: %v\n", err)
 		return 1
 	}
-	defer conn.Close()
-	c := pb.NewRuntimeMetricServiceClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	// get the metrics
-	r, err := c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: MEMORY_USAGE})
-	if err != nil {
-		debugLogf("Could not get MEMORY_USAGE metrics: %v\n", err)
-		return 2
-	}
-	device_ids, memory_usage := getSortedMetrics(r, func(x *pb.Gauge) int64 { return x.GetAsInt() })
-	if count != len(device_ids) {
-		debugLogf("%d metrics found, but that doesn't match the discovered number of chips: %d\n", len(device_ids), count)
-		return 2
-	}
-
-	// check for number of metric agreement early before checking other metrics
-	if int(n) != len(device_ids) {
-		debugLogf("Asked for metrics for %d chips, but %d chips found\n", int(n), len(device_ids))
-		return 2
+	metrics, err := client.DeviceMetrics(context.Background())
+	if err != nil && port <= 0 {
+		// several processes, each serving the metrics of its own chips, or
+		// none of them on the default port
+		debugLogf("Could not get TPU metrics from %s: %v; merging every owner's metrics server\n", client.Target(), err)
+		merged, merr := tpuinfo.GetDiscoveredMetrics(context.Background())
+		if merr != nil {
+			debugLogf("Could not get every owner's TPU metrics: %v\n", merr)
+		}
+		if merged != nil && len(merged.DeviceIDs) == count {
+			metrics, err = merged, nil
+		}
 	}
-
-	r, err = c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: TOTAL_MEMORY})
 	if err != nil {
-		debugLogf("Could not get TOTAL_MEMORY metrics: %v\n", err)
+		debugLogf("Could not get TPU metrics: %v\n", err)
+		switch {
+		case errors.Is(err, tpuinfo.ErrConnect):
+			return 1
+		case errors.Is(err, tpuinfo.ErrInvalidMetrics):
+			return 3
+		}
 		return 2
 	}
-	_, total_memory := getSortedMetrics(r, func(x *pb.Gauge) int64 { return x.GetAsInt() })
 
-	r, err = c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: DUTY_CYCLE_PCT})
-	if err != nil {
-		debugLogf("Could not get DUTY_CYCLE_PCT metrics: %v\n", err)
-		return 2
-	}
-	_, duty_cycle_pct := getSortedMetrics(r, func(x *pb.Gauge) float64 { return x.GetAsDouble() })
+	copyValuesToC(device_ids_, metrics.DeviceIDs, func(a int) C.longlong { return C.longlong(a) })
+	copyValuesToC(memory_usage_, metrics.MemoryUsage, func(a int64) C.longlong { return C.longlong(a) })
+	copyValuesToC(total_memory_, metrics.TotalMemory, func(a int64) C.longlong { return C.longlong(a) })
+	copyValuesToC(duty_cycle_pct_, metrics.DutyCyclePct, func(a float64) C.double { return C.double(a) })
 
-	// Duty cycle is always measured per-chip, while memory is measured per-core.
-	// Repeat if necessary so these responses are the same length.
-	cores_per_chip := len(total_memory) / len(duty_cycle_pct)
-	duty_cycle_per_core_pct := make([]float64, len(total_memory))
+	return 0
+}
 
-	for i := 0; i < len(duty_cycle_pct); i++ {
-		for j := 0; j < cores_per_chip; j++ {
-			duty_cycle_per_core_pct[cores_per_chip*i+j] = duty_cycle_pct[i]
+//export tpu_metrics_discovered
+func tpu_metrics_discovered(device_ids_ *C.longlong, memory_usage_ *C.longlong, total_memory_ *C.longlong, duty_cycle_pct_ *C.double, n C.int, count *C.int) C.int {
+	metrics, err := tpuinfo.GetDiscoveredMetrics(context.Background())
+	if err != nil {
+		debugLogf("Could not get TPU metrics: %v\n", err)
+		if metrics == nil {
+			switch {
+			case errors.Is(err, tpuinfo.ErrNoEndpoints), errors.Is(err, tpuinfo.ErrConnect):
+				return 1
+			case errors.Is(err, tpuinfo.ErrInvalidMetrics):
+				return 3
+			}
+			return 2
 		}
 	}
-	// check that the info length matches for all statistics
-	if len(device_ids) != len(memory_usage) || len(total_memory) != len(memory_usage) || len(memory_usage) != len(duty_cycle_per_core_pct) {
-		debugLogf("Lengths of metrics do not agree. len(total_memory) = %d; len(memory_usage) = %d; len(duty_cycle_per_core_pct) = %d\n",
-			len(total_memory), len(memory_usage), len(duty_cycle_per_core_pct))
-		return 3
+	if count != nil {
+		*count = C.int(len(metrics.DeviceIDs))
 	}
-
-	copyValuesToC(device_ids_, device_ids, func(a int) C.longlong { return C.longlong(a) })
-	copyValuesToC(memory_usage_, memory_usage, func(a int64) C.longlong { return C.longlong(a) })
-	copyValuesToC(total_memory_, total_memory, func(a int64) C.longlong { return C.longlong(a) })
-	copyValuesToC(duty_cycle_pct_, duty_cycle_per_core_pct, func(a float64) C.double { return C.double(a) })
-
+	m := min(int(n), len(metrics.DeviceIDs))
+	if m <= 0 {
+		return 0
+	}
+	copyValuesToC(device_ids_, metrics.DeviceIDs[:m], func(a int) C.longlong { return C.longlong(a) })
+	copyValuesToC(memory_usage_, metrics.MemoryUsage[:m], func(a int64) C.longlong { return C.longlong(a) })
+	copyValuesToC(total_memory_, metrics.TotalMemory[:m], func(a int64) C.longlong { return C.longlong(a) })
+	copyValuesToC(duty_cycle_pct_, metrics.DutyCyclePct[:m], func(a float64) C.double { return C.double(a) })
 	return 0
 }
 
-type Metrics struct {
-	DeviceIDs    []int
-	MemoryUsage  []int64
-	TotalMemory  []int64
-	DutyCyclePct []float64
+//export tpu_process_usages
+func tpu_process_usages(rows *C.tpu_process_usage, n C.int, count *C.int) C.int {
+	usage, err := tpuinfo.GetProcessUsage(context.Background())
+	if err != nil {
+		debugLogf("Could not get the TPU usage of processes: %v\n", err)
+		if usage == nil {
+			switch {
+			case errors.Is(err, tpuinfo.ErrConnect):
+				return 1
+			case errors.Is(err, tpuinfo.ErrInvalidMetrics):
+				return 3
+			}
+			return 2
+		}
+	}
+	if count != nil {
+		*count = C.int(len(usage))
+	}
+	m := min(int(n), len(usage))
+	if m <= 0 {
+		return 0
+	}
+	out := unsafe.Slice(rows, m)
+	for i, u := range usage[:m] {
+		out[i] = C.tpu_process_usage{
+			pid:            C.longlong(u.Process.Pid),
+			start_ticks:    C.ulonglong(u.Process.StartTicks),
+			uid:            C.int(u.Process.UID),
+			chips:          C.int(len(u.Chips)),
+			devices:        C.int(len(u.Devices)),
+			memory_usage:   C.longlong(u.MemoryUsage),
+			total_memory:   C.longlong(u.TotalMemory),
+			duty_cycle_pct: C.double(u.DutyCyclePct),
+		}
+		if u.Shared {
+			out[i].shared = 1
+		}
+		copyStringToC(&out[i].user[0], len(out[i].user), u.Process.User)
+		copyStringToC(&out[i].container_id[0], len(out[i].container_id), u.Process.ContainerID)
+	}
+	return 0
 }
 
-func GetMetrics() *Metrics {
-
-	// connect to the server
-	addr := "localhost:8431"
-	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+//export tpu_metric_by_name
+func tpu_metric_by_name(port C.int, name *C.char, samples *C.tpu_metric_sample, n C.int, count *C.int) C.int {
+	client, err := tpuinfo.SharedClient(tpuinfo.WithPort(int(port)))
 	if err != nil {
-		debugLogf("Could not connect to the GRPC server: %v\n", err)
-		return nil
+		debugLogf("Could not connect to the TPU metrics GRPC server: %v\n", err)
+		return 1
 	}
-	defer conn.Close()
-	c := pb.NewRuntimeMetricServiceClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	// get the metrics
-	r, err := c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: MEMORY_USAGE})
+	metric, err := client.MetricByName(context.Background(), C.GoString(name))
 	if err != nil {
-		debugLogf("Could not get MEMORY_USAGE metrics: %v\n", err)
-		return nil
+		debugLogf("Could not get metric %s: %v\n", C.GoString(name), err)
+		return 2
 	}
-	device_ids, memory_usage := getSortedMetrics(r, func(x *pb.Gauge) int64 { return x.GetAsInt() })
+	if count != nil {
+		*count = C.int(len(metric.Samples))
+	}
+	m := min(int(n), len(metric.Samples))
+	if m <= 0 {
+		return 0
+	}
+	samples_go := unsafe.Slice(samples, m)
+	for i, s := range metric.Samples[:m] {
+		out := &samples_go[i]
+		out.device_id = -1
+		if id, ok := s.DeviceID(); ok {
+			out.device_id = C.longlong(id)
+		}
+		out.kind = C.int(s.Value.Kind)
+		out.as_double = C.double(s.Value.Float())
+		out.as_int = C.longlong(s.Value.Int)
+		switch {
+		case s.Value.Kind == tpuinfo.KindUint:
+			out.as_int = C.longlong(s.Value.Uint)
+		case s.Value.Kind == tpuinfo.KindBool && s.Value.Bool:
+			out.as_int = 1
+		}
+		copyStringToC(&out.as_string[0], C.TPU_STRING_LEN, s.Value.String())
+		out.timestamp_ns = 0
+		if !s.Timestamp.IsZero() {
+			out.timestamp_ns = C.longlong(s.Timestamp.UnixNano())
+		}
+		copyStringToC(&out.labels[0], C.TPU_STRING_LEN, s.LabelString())
+	}
+	return 0
+}
 
-	r, err = c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: TOTAL_MEMORY})
+//export tpu_metric_catalog
+func tpu_metric_catalog(port C.int, filter *C.char, infos *C.tpu_metric_info, n C.int, count *C.int) C.int {
+	client, err := tpuinfo.SharedClient(tpuinfo.WithPort(int(port)))
 	if err != nil {
-		debugLogf("Could not get TOTAL_MEMORY metrics: %v\n", err)
-		return nil
+		debugLogf("Could not connect to the TPU metrics GRPC server: %v\n", err)
+		return 1
 	}
-	_, total_memory := getSortedMetrics(r, func(x *pb.Gauge) int64 { return x.GetAsInt() })
-
-	r, err = c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: DUTY_CYCLE_PCT})
+	filter_go := ""
+	if filter != nil {
+		filter_go = C.GoString(filter)
+	}
+	catalog, err := client.Catalog(context.Background(), filter_go)
 	if err != nil {
-		debugLogf("Could not get DUTY_CYCLE_PCT metrics: %v\n", err)
-		return nil
-	}
-	_, duty_cycle_pct := getSortedMetrics(r, func(x *pb.Gauge) float64 { return x.GetAsDouble() })
-
-	// Duty cycle is always measured per-chip, while memory is measured per-core.
-	// Repeat if necessary so these responses are the same length.
-	cores_per_chip := len(total_memory) / len(duty_cycle_pct)
-	duty_cycle_per_core_pct := make([]float64, len(total_memory))
-	for i := 0; i < len(duty_cycle_pct); i++ {
-		for j := 0; j < cores_per_chip; j++ {
-			duty_cycle_per_core_pct[cores_per_chip*i+j] = duty_cycle_pct[i]
-		}
+		debugLogf("Could not list supported metrics: %v\n", err)
+		return 2
 	}
-
-	// check that the info length matches for all statistics
-	if len(device_ids) != len(memory_usage) || len(total_memory) != len(memory_usage) || len(memory_usage) != len(duty_cycle_per_core_pct) {
-		debugLogf(`Lengths of metrics do not agree. len(total_memory) = %d; 
-								len(memory_usage) = %d; len(duty_cycle_per_core_pct) = %d\n`,
-			len(total_memory), len(memory_usage), len(duty_cycle_per_core_pct))
-		return nil
+	if count != nil {
+		*count = C.int(len(catalog))
 	}
-
-	return &Metrics{device_ids, memory_usage, total_memory, duty_cycle_per_core_pct}
+	m := min(int(n), len(catalog))
+	if m <= 0 {
+		return 0
+	}
+	infos_go := unsafe.Slice(infos, m)
+	for i, d := range catalog[:m] {
+		copyStringToC(&infos_go[i].name[0], C.TPU_STRING_LEN, d.Name)
+		copyStringToC(&infos_go[i].description[0], 2*C.TPU_STRING_LEN, d.Description)
+		infos_go[i].measure = C.int(d.Measure)
+		infos_go[i].kind = C.int(d.Kind)
+	}
+	return 0
 }
 
 func main() {
-	DebugEnabled = true
+	tpuinfo.DebugEnabled = true
 
 	t := time.Now()
-	chip_type, count := getLocalChips()
+	chip_counts, err := tpuinfo.ChipCounts()
 	debugLogf("Finding chips takes %d us\n", time.Since(t).Microseconds())
-
-	debugLogf("Found %d chips\n", count)
+	if err != nil {
+		debugLogf("Could not discover TPU chips: %v\n", err)
+		return
+	}
+	debugLogf("Found chips %v\n", chip_counts)
 
 	pid := int64(-1)
 	for i := 0; i < 10; i++ {
 		t = time.Now()
-		chip_owners, err := getChipProcessOwners()
+		chip_owners, err := tpuinfo.GetChipProcessOwners()
 		debugLogf("Finding chip process owners takes %d us\n", time.Since(t).Microseconds())
 		if err != nil {
 			debugLogf("Could not get chip owners: %v\n", err)
-			os.Exit(1)
+			return
 		}
 		for _, k := range chip_owners {
 			pid = k
@@ -507,18 +739,15 @@ func main() {
 
 	for i := 0; i < 10; i++ {
 		t = time.Now()
-		metrics_ptr := GetMetrics()
-		if metrics_ptr == nil {
-			debugLogf("Could not get metrics\n")
+		metrics, err := tpuinfo.GetMetrics(context.Background())
+		if err != nil {
+			debugLogf("Could not get metrics: %v\n", err)
 			debugLogf("Getting metrics takes %d us\n", time.Since(t).Microseconds())
+			continue
 		}
-		if metrics_ptr != nil {
-			metrics := *metrics_ptr
-			// fmt.Printf("Device Id Memory Usage Total Memory Duty Cycle Pct\n")  // skip header
-			for i, _ := range metrics.DeviceIDs {
-				debugLogf("%d %d %d %.2f %s %d\n", metrics.DeviceIDs[i], metrics.MemoryUsage[i], metrics.TotalMemory[i],
-					metrics.DutyCyclePct[i], chip_type.Value.Name, pid)
-			}
+		for i := range metrics.DeviceIDs {
+			debugLogf("%d %d %d %.2f %d\n", metrics.DeviceIDs[i], metrics.MemoryUsage[i], metrics.TotalMemory[i],
+				metrics.DutyCyclePct[i], pid)
 		}
 	}
 }
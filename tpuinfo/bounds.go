@@ -0,0 +1,132 @@
+package tpuinfo
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ProcessBounds is the slice of the host a libtpu process was started with,
+// from its environment. Unset variables are nil.
+type ProcessBounds struct {
+	// VisibleChips is TPU_VISIBLE_CHIPS: the host chips the process uses, the
+	// i-th being its local chip i.
+	VisibleChips []int
+	// ChipsPerProcessBounds is TPU_CHIPS_PER_PROCESS_BOUNDS, e.g. [2 1 1].
+	ChipsPerProcessBounds []int
+	// ProcessBounds is TPU_PROCESS_BOUNDS, e.g. [1 2 1] for two processes.
+	ProcessBounds []int
+}
+
+// parseIntList parses "0,1,2" lists; nil if any element is not an integer.
+func parseIntList(list string) []int {
+	var ints []int
+	for _, field := range strings.Split(list, ",") {
+		v, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			return nil
+		}
+		ints = append(ints, v)
+	}
+	return ints
+}
+
+func parseProcessBounds(env map[string]string) ProcessBounds {
+	var b ProcessBounds
+	if v, ok := env["TPU_VISIBLE_CHIPS"]; ok {
+		b.VisibleChips = parseIntList(v)
+	}
+	if v, ok := env["TPU_CHIPS_PER_PROCESS_BOUNDS"]; ok {
+		b.ChipsPerProcessBounds = parseIntList(v)
+	}
+	if v, ok := env["TPU_PROCESS_BOUNDS"]; ok {
+		b.ProcessBounds = parseIntList(v)
+	}
+	return b
+}
+
+// ReadProcessBounds reads the TPU bounds of a process from
+// /proc/<pid>/environ, which is only readable for processes of the same user
+// or by root.
+func ReadProcessBounds(pid int64) ProcessBounds {
+	return parseProcessBounds(readEnviron(strconv.FormatInt(pid, 10)))
+}
+
+func product(bounds []int) int {
+	if len(bounds) == 0 {
+		return 0
+	}
+	n := 1
+	for _, b := range bounds {
+		n *= b
+	}
+	return n
+}
+
+// ChipsPerProcess is the number of chips per process, 0 if unset.
+func (b ProcessBounds) ChipsPerProcess() int {
+	return product(b.ChipsPerProcessBounds)
+}
+
+// Processes is the number of processes sharing the slice, 0 if unset.
+func (b ProcessBounds) Processes() int {
+	return product(b.ProcessBounds)
+}
+
+// resolveVisibleChips maps TPU_VISIBLE_CHIPS to Device.Index, in local chip
+// order. libtpu numbers host chips by their /dev/accel<N> node and, for chips
+// bound to vfio-pci, in PCI order, which is Device.Index.
+func resolveVisibleChips(devices []Device, b ProcessBounds) ([]int, error) {
+	if n := b.ChipsPerProcess(); n > 0 && n != len(b.VisibleChips) {
+		return nil, fmt.Errorf("TPU_VISIBLE_CHIPS lists %d chips, TPU_CHIPS_PER_PROCESS_BOUNDS %v is %d", len(b.VisibleChips), b.ChipsPerProcessBounds, n)
+	}
+	chips := make([]int, len(b.VisibleChips))
+	for i, v := range b.VisibleChips {
+		chips[i] = -1
+		for _, d := range devices {
+			if d.DevPath == fmt.Sprintf("/dev/accel%d", v) {
+				chips[i] = d.Index
+				break
+			}
+		}
+		if chips[i] < 0 {
+			if v < 0 || v >= len(devices) {
+				return nil, fmt.Errorf("TPU_VISIBLE_CHIPS chip %d is not on this host", v)
+			}
+			chips[i] = v
+		}
+	}
+	return chips, nil
+}
+
+// localToHost maps the device ids a process's metrics server reports to
+// canonical device indexes. Runtime ids are local to the process: id d is core
+// d % cores of local chip d / cores, and local chip i is chips[i]. If the ids
+// do not fit that numbering they are matched in ascending order instead.
+func localToHost(mapping []DeviceMapping, chips []int, local_ids []int) ([]int, error) {
+	chipDevices := make(map[int][]int)
+	for _, m := range mapping {
+		chipDevices[m.Chip] = append(chipDevices[m.Chip], m.Index)
+	}
+	var devices []int
+	for _, chip := range chips {
+		devices = append(devices, chipDevices[chip]...)
+	}
+	if len(devices) != len(local_ids) {
+		return nil, fmt.Errorf("%w: %d runtime devices for %d devices on chips %v", ErrDeviceIndexMismatch, len(local_ids), len(devices), chips)
+	}
+	if len(chips) == 0 {
+		return devices, nil
+	}
+	cores := len(devices) / len(chips)
+	host := make([]int, len(local_ids))
+	for i, id := range local_ids {
+		local_chip := id / cores
+		if id < 0 || local_chip >= len(chips) || len(chipDevices[chips[local_chip]]) != cores {
+			// not process-local ids; local_ids are ascending
+			return devices, nil
+		}
+		host[i] = chipDevices[chips[local_chip]][id%cores]
+	}
+	return host, nil
+}
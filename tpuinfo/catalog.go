@@ -0,0 +1,120 @@
+package tpuinfo
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"sync"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+// CatalogTTL is how long a Client reuses a fetched metric catalog.
+const CatalogTTL = time.Minute
+
+// catalogConcurrency bounds the metric RPCs in flight while describing the
+// supported metrics.
+const catalogConcurrency = 8
+
+// MetricDescriptor describes one metric supported by the running libtpu.
+// Measure and Kind are inferred from a sample of the metric and are unknown
+// when the metric currently has no samples.
+type MetricDescriptor struct {
+	Name        string
+	Description string
+	Measure     MeasureType
+	Kind        ValueKind
+}
+
+type catalogEntry struct {
+	metrics []MetricDescriptor
+	fetched time.Time
+}
+
+func describeMetric(name string, r *pb.MetricResponse) MetricDescriptor {
+	d := MetricDescriptor{Name: name, Description: r.GetMetric().GetDescription()}
+	if ms := r.GetMetric().GetMetrics(); len(ms) > 0 {
+		s := decodeSample(ms[0])
+		d.Measure, d.Kind = s.Measure, s.Value.Kind
+	}
+	return d
+}
+
+// Catalog lists the metrics supported by the server whose names match the
+// regex filter (all metrics if empty), with descriptions and inferred kinds.
+// Results are cached per filter for CatalogTTL; the returned slice is the
+// caller's to modify.
+func (c *Client) Catalog(ctx context.Context, filter string) ([]MetricDescriptor, error) {
+	if _, err := regexp.Compile(filter); err != nil {
+		return nil, &MetricsError{Target: c.target, Err: fmt.Errorf("invalid filter: %w", err)}
+	}
+	c.catalogMu.Lock()
+	entry, ok := c.catalog[filter]
+	c.catalogMu.Unlock()
+	if ok && time.Since(entry.fetched) < CatalogTTL {
+		return append([]MetricDescriptor(nil), entry.metrics...), nil
+	}
+
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	var r *pb.ListSupportedMetricsResponse
+	err := c.invoke(ctx, "", func(rpc pb.RuntimeMetricServiceClient) (err error) {
+		r, err = rpc.ListSupportedMetrics(ctx, &pb.ListSupportedMetricsRequest{Filter: filter})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a metric that can't be fetched right now is still listed, just without
+	// a description or kind
+	supported := r.GetSupportedMetric()
+	metrics := make([]MetricDescriptor, len(supported))
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, catalogConcurrency)
+	for i, m := range supported {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			name := m.GetMetricName()
+			r, err := c.getRuntimeMetric(ctx, name)
+			if err != nil {
+				Debugf("Could not describe metric %s: %v\n", name, err)
+				metrics[i] = MetricDescriptor{Name: name}
+				return
+			}
+			metrics[i] = describeMetric(name, r)
+		}()
+	}
+	wg.Wait()
+	sort.Slice(metrics, func(i, j int) bool { return metrics[i].Name < metrics[j].Name })
+
+	c.catalogMu.Lock()
+	if c.catalog == nil {
+		c.catalog = make(map[string]catalogEntry)
+	}
+	c.catalog[filter] = catalogEntry{metrics: metrics, fetched: time.Now()}
+	c.catalogMu.Unlock()
+	return append([]MetricDescriptor(nil), metrics...), nil
+}
+
+// InvalidateCatalog drops the cached catalog so the next Catalog call queries
+// the server again.
+func (c *Client) InvalidateCatalog() {
+	c.catalogMu.Lock()
+	defer c.catalogMu.Unlock()
+	c.catalog = nil
+}
+
+// GetCatalog lists the metrics supported by the default local metrics server.
+func GetCatalog(ctx context.Context, filter string) ([]MetricDescriptor, error) {
+	c, err := SharedClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.Catalog(ctx, filter)
+}
@@ -0,0 +1,125 @@
+package tpuinfo
+
+import (
+	"context"
+	"regexp"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+// catalogMetricServer lists the metrics of a staticMetricServer and counts
+// the ListSupportedMetrics calls.
+type catalogMetricServer struct {
+	staticMetricServer
+	lists atomic.Int32
+}
+
+func (s *catalogMetricServer) ListSupportedMetrics(ctx context.Context, req *pb.ListSupportedMetricsRequest) (*pb.ListSupportedMetricsResponse, error) {
+	s.lists.Add(1)
+	re := regexp.MustCompile(req.GetFilter())
+	r := &pb.ListSupportedMetricsResponse{}
+	for name := range s.metrics {
+		if re.MatchString(name) {
+			r.SupportedMetric = append(r.SupportedMetric, &pb.SupportedMetric{MetricName: name})
+		}
+	}
+	return r, nil
+}
+
+func newCatalogClient(t *testing.T) (*Client, *catalogMetricServer) {
+	t.Helper()
+	ts := time.Now()
+	srv := &catalogMetricServer{staticMetricServer: staticMetricServer{metrics: map[string]*pb.TPUMetric{
+		MEMORY_USAGE:   {Name: MEMORY_USAGE, Description: "HBM in use", Metrics: []*pb.Metric{gaugeMetric(0, ts, &pb.Gauge{Value: &pb.Gauge_AsInt{AsInt: 1}})}},
+		DUTY_CYCLE_PCT: {Name: DUTY_CYCLE_PCT, Description: "duty cycle", Metrics: []*pb.Metric{gaugeMetric(0, ts, &pb.Gauge{Value: &pb.Gauge_AsDouble{AsDouble: 50}})}},
+	}}}
+	c, err := NewClient(WithTarget(startFakeMetricServer(t, srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { c.Close() })
+	return c, srv
+}
+
+func TestCatalogDescribesMetrics(t *testing.T) {
+	c, _ := newCatalogClient(t)
+	got, err := c.Catalog(context.Background(), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []MetricDescriptor{
+		{Name: MEMORY_USAGE, Description: "HBM in use", Measure: MeasureGauge, Kind: KindInt},
+		{Name: DUTY_CYCLE_PCT, Description: "duty cycle", Measure: MeasureGauge, Kind: KindDouble},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("Catalog() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("Catalog()[%d] = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
+
+func TestCatalogCache(t *testing.T) {
+	c, srv := newCatalogClient(t)
+	ctx := context.Background()
+	first, err := c.Catalog(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the returned slice is a copy of the cached one
+	first[0].Name = "modified"
+	second, err := c.Catalog(ctx, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second[0].Name == "modified" {
+		t.Error("modifying the returned catalog changed the cached one")
+	}
+	if n := srv.lists.Load(); n != 1 {
+		t.Errorf("ListSupportedMetrics called %d times within CatalogTTL, want 1", n)
+	}
+
+	// each filter is cached separately
+	if _, err := c.Catalog(ctx, "duty"); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.lists.Load(); n != 2 {
+		t.Errorf("ListSupportedMetrics called %d times after a new filter, want 2", n)
+	}
+
+	// an entry older than CatalogTTL is refetched
+	c.catalogMu.Lock()
+	entry := c.catalog[""]
+	entry.fetched = time.Now().Add(-CatalogTTL)
+	c.catalog[""] = entry
+	c.catalogMu.Unlock()
+	if _, err := c.Catalog(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.lists.Load(); n != 3 {
+		t.Errorf("ListSupportedMetrics called %d times after CatalogTTL, want 3", n)
+	}
+
+	c.InvalidateCatalog()
+	if _, err := c.Catalog(ctx, ""); err != nil {
+		t.Fatal(err)
+	}
+	if n := srv.lists.Load(); n != 4 {
+		t.Errorf("ListSupportedMetrics called %d times after InvalidateCatalog, want 4", n)
+	}
+}
+
+func TestCatalogInvalidFilter(t *testing.T) {
+	c, srv := newCatalogClient(t)
+	if _, err := c.Catalog(context.Background(), "("); err == nil {
+		t.Error("Catalog(\"(\") succeeded, want an error")
+	}
+	if n := srv.lists.Load(); n != 0 {
+		t.Errorf("ListSupportedMetrics called %d times for an invalid filter, want 0", n)
+	}
+}
@@ -0,0 +1,125 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+)
+
+type TpuChipInfo struct {
+	Name           string
+	HBMGiB         int
+	DevicesPerChip int
+	// TensorCores is the number of TensorCores per chip. With Megacore the
+	// TensorCores of a chip are driven together as a single device.
+	TensorCores int
+	Megacore    bool
+	// HostBounds is the chip grid of a full host, x by y by z, with ICI links
+	// between neighbouring chips.
+	HostBounds [3]int
+}
+
+type TpuChip struct {
+	Value TpuChipInfo
+}
+
+var (
+	V2  = TpuChip{Value: TpuChipInfo{Name: "v2", HBMGiB: 8, DevicesPerChip: 2, TensorCores: 2, HostBounds: [3]int{2, 2, 1}}}
+	V3  = TpuChip{Value: TpuChipInfo{Name: "v3", HBMGiB: 16, DevicesPerChip: 2, TensorCores: 2, HostBounds: [3]int{2, 2, 1}}}
+	V4  = TpuChip{Value: TpuChipInfo{Name: "v4", HBMGiB: 32, DevicesPerChip: 1, TensorCores: 2, Megacore: true, HostBounds: [3]int{2, 2, 1}}}
+	V5E = TpuChip{Value: TpuChipInfo{Name: "v5e", HBMGiB: 16, DevicesPerChip: 1, TensorCores: 1, HostBounds: [3]int{2, 4, 1}}}
+	V5P = TpuChip{Value: TpuChipInfo{Name: "v5p", HBMGiB: 95, DevicesPerChip: 1, TensorCores: 2, Megacore: true, HostBounds: [3]int{2, 2, 1}}}
+	V6E = TpuChip{Value: TpuChipInfo{Name: "v6e", HBMGiB: 32, DevicesPerChip: 1, TensorCores: 1, HostBounds: [3]int{2, 4, 1}}}
+)
+
+func (t TpuChip) String() string {
+	return t.Value.Name
+}
+
+// FromPCIDeviceID maps a Google PCI device/subsystem id pair to a chip type,
+// returning nil for unknown devices.
+func FromPCIDeviceID(deviceID, subsystemID string) *TpuChip {
+	// TPU v2 and v3 share a device ID
+	if deviceID == "0x0027" {
+		if subsystemID == "0x004e" {
+			return &V2
+		} else if subsystemID == "0x004f" {
+			return &V3
+		}
+	}
+
+	deviceIDToDevice := map[string]*TpuChip{
+		"0x005e": &V4,
+		"0x0063": &V5E,
+		"0x0062": &V5P,
+		"0x006f": &V6E,
+	}
+
+	if chip, ok := deviceIDToDevice[deviceID]; ok {
+		return chip
+	}
+	return nil
+}
+
+// ErrMixedChipTypes is returned by GetLocalChips on a host with more than one
+// TPU generation; use GetLocalChipTypes or ChipCounts there instead.
+var ErrMixedChipTypes = errors.New("more than one TPU chip type on this host")
+
+// GetLocalChipTypes returns the type of every TPU chip on the PCI bus, in PCI
+// address order. Hosts mixing TPU generations are reported as they are.
+func GetLocalChipTypes() ([]*TpuChip, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	chips := make([]*TpuChip, len(devices))
+	for i, d := range devices {
+		chips[i] = d.Chip
+	}
+	return chips, nil
+}
+
+// ChipCounts returns the number of chips per chip type name.
+func ChipCounts() (map[string]int, error) {
+	chips, err := GetLocalChipTypes()
+	if err != nil {
+		return nil, err
+	}
+	count := make(map[string]int)
+	for _, chip := range chips {
+		count[chip.Value.Name]++
+	}
+	return count, nil
+}
+
+// GetLocalChips returns the TPU chip type on this host and the number of chips
+// found on the PCI bus. A host without TPUs returns (nil, 0, nil) and a host
+// with several chip types returns ErrMixedChipTypes along with the total count.
+func GetLocalChips() (*TpuChip, int, error) {
+	chips, err := GetLocalChipTypes()
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(chips) == 0 {
+		return nil, 0, nil
+	}
+	for _, chip := range chips[1:] {
+		if chip != chips[0] {
+			return nil, len(chips), fmt.Errorf("%w: %v", ErrMixedChipTypes, chips)
+		}
+	}
+	return chips[0], len(chips), nil
+}
+
+// DeviceCount returns the number of TPU devices (cores addressed by the
+// runtime) on this host, i.e. the sum of DevicesPerChip over all chips.
+func DeviceCount() (int, error) {
+	chips, err := GetLocalChipTypes()
+	if err != nil {
+		return 0, err
+	}
+	count := 0
+	for _, chip := range chips {
+		count += chip.Value.DevicesPerChip
+	}
+	return count, nil
+}
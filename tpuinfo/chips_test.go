@@ -0,0 +1,57 @@
+package tpuinfo
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMixedChipTypes(t *testing.T) {
+	// two v4 chips and one v2 chip
+	useFakeSysfs(t,
+		fakePCIDevice{"0000:00:04.0", googlePCIVendorID, "0x005e", "0x0000", -1},
+		fakePCIDevice{"0000:00:05.0", googlePCIVendorID, "0x0027", "0x004e", -1},
+		fakePCIDevice{"0000:00:06.0", googlePCIVendorID, "0x005e", "0x0000", -1},
+	)
+	types, err := GetLocalChipTypes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []*TpuChip{&V4, &V2, &V4}; !reflect.DeepEqual(types, want) {
+		t.Errorf("GetLocalChipTypes() = %v, want %v", types, want)
+	}
+	counts, err := ChipCounts()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"v4": 2, "v2": 1}; !reflect.DeepEqual(counts, want) {
+		t.Errorf("ChipCounts() = %v, want %v", counts, want)
+	}
+	// one device per v4 chip, two per v2 chip
+	if n, err := DeviceCount(); err != nil || n != 4 {
+		t.Errorf("DeviceCount() = %d, %v, want 4", n, err)
+	}
+	chip, n, err := GetLocalChips()
+	if !errors.Is(err, ErrMixedChipTypes) || chip != nil || n != 3 {
+		t.Errorf("GetLocalChips() = %v, %d, %v, want nil, 3, ErrMixedChipTypes", chip, n, err)
+	}
+}
+
+func TestSingleChipType(t *testing.T) {
+	useFakeSysfs(t,
+		fakePCIDevice{"0000:00:04.0", googlePCIVendorID, "0x006f", "0x0000", -1},
+		fakePCIDevice{"0000:00:05.0", googlePCIVendorID, "0x006f", "0x0000", -1},
+	)
+	chip, n, err := GetLocalChips()
+	if err != nil || chip != &V6E || n != 2 {
+		t.Errorf("GetLocalChips() = %v, %d, %v, want v6e, 2", chip, n, err)
+	}
+}
+
+func TestNoChips(t *testing.T) {
+	useFakeSysfs(t)
+	chip, n, err := GetLocalChips()
+	if err != nil || chip != nil || n != 0 {
+		t.Errorf("GetLocalChips() = %v, %d, %v, want nil, 0, nil", chip, n, err)
+	}
+}
@@ -0,0 +1,296 @@
+package tpuinfo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const DefaultTimeout = time.Second
+
+// RetryPolicy controls how a Client retries a runtime metric RPC that failed
+// with a transient status (Unavailable, ResourceExhausted or Aborted). A
+// MaxAttempts of 0 or 1 disables retries.
+type RetryPolicy struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+}
+
+// DefaultRetryPolicy performs a single attempt.
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 1, InitialBackoff: 50 * time.Millisecond, MaxBackoff: time.Second, Multiplier: 2}
+
+func (p RetryPolicy) backoff(attempt int) time.Duration {
+	d := float64(p.InitialBackoff)
+	for i := 1; i < attempt; i++ {
+		d *= p.Multiplier
+	}
+	if p.MaxBackoff > 0 && d > float64(p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+	return time.Duration(d)
+}
+
+func isRetryable(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.ResourceExhausted, codes.Aborted:
+		return true
+	}
+	return false
+}
+
+// MetricsError is returned by every Client fetch. Metric is empty when the
+// failure is not specific to one metric (e.g. connecting). Err wraps
+// ErrConnect, ErrInvalidMetrics or the gRPC status of the failed call.
+type MetricsError struct {
+	Target string
+	Metric string
+	Err    error
+}
+
+func (e *MetricsError) Error() string {
+	if e.Metric == "" {
+		return fmt.Sprintf("%s: %v", e.Target, e.Err)
+	}
+	return fmt.Sprintf("%s: metric %s: %v", e.Target, e.Metric, e.Err)
+}
+
+func (e *MetricsError) Unwrap() error {
+	return e.Err
+}
+
+// Client is a long-lived client for the TPU runtime metrics gRPC server.
+type Client struct {
+	target              string
+	timeout             time.Duration
+	creds               credentials.TransportCredentials
+	dialOpts            []grpc.DialOption
+	retry               RetryPolicy
+	skipNodeAggregation bool
+
+	mu   sync.Mutex
+	conn *grpc.ClientConn
+
+	catalogMu sync.Mutex
+	catalog   map[string]catalogEntry
+}
+
+type Option func(*Client)
+
+// WithTarget sets the gRPC target of the metrics server, e.g. "localhost:8431"
+// or "unix:///run/tpu.sock".
+func WithTarget(target string) Option {
+	return func(c *Client) { c.target = target }
+}
+
+// WithPort targets the metrics server on localhost:port. A port <= 0 keeps the
+// default (LIBTPUINFO_GRPC_PORT or 8431).
+func WithPort(port int) Option {
+	return func(c *Client) {
+		if port > 0 {
+			c.target = fmt.Sprintf("localhost:%d", port)
+		}
+	}
+}
+
+// WithTimeout sets the deadline applied to each fetch on top of the caller's
+// context.
+func WithTimeout(timeout time.Duration) Option {
+	return func(c *Client) { c.timeout = timeout }
+}
+
+// WithDialOptions appends extra grpc.DialOptions used to create the connection.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) { c.dialOpts = append(c.dialOpts, opts...) }
+}
+
+// WithTransportCredentials replaces the default insecure credentials.
+func WithTransportCredentials(creds credentials.TransportCredentials) Option {
+	return func(c *Client) { c.creds = creds }
+}
+
+// WithRetry sets the retry/backoff policy for individual RPCs.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) { c.retry = policy }
+}
+
+// WithSkipNodeAggregation sets skip_node_aggregation on every MetricRequest.
+func WithSkipNodeAggregation(skip bool) Option {
+	return func(c *Client) { c.skipNodeAggregation = skip }
+}
+
+// NewClient creates a metrics client. The connection is established lazily on
+// the first fetch and reused by every later fetch until Close.
+func NewClient(opts ...Option) (*Client, error) {
+	c := &Client{
+		target:  fmt.Sprintf("localhost:%d", DefaultGRPCPort),
+		timeout: DefaultTimeout,
+		creds:   insecure.NewCredentials(),
+		retry:   DefaultRetryPolicy,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.target == "" {
+		return nil, &MetricsError{Target: c.target, Err: fmt.Errorf("%w: empty target", ErrConnect)}
+	}
+	return c, nil
+}
+
+var sharedClients = struct {
+	sync.Mutex
+	m map[string]*Client
+}{m: make(map[string]*Client)}
+
+// SharedClient returns the process-wide Client for the target selected by
+// opts, creating it on first use. The remaining options only apply when the
+// client is created. Shared clients must not be closed.
+func SharedClient(opts ...Option) (*Client, error) {
+	c, err := NewClient(opts...)
+	if err != nil {
+		return nil, err
+	}
+	sharedClients.Lock()
+	defer sharedClients.Unlock()
+	if shared, ok := sharedClients.m[c.target]; ok {
+		return shared, nil
+	}
+	sharedClients.m[c.target] = c
+	return c, nil
+}
+
+// connection returns the client's connection, dialing it if there is none yet
+// and replacing it if it was shut down. A connection in TRANSIENT_FAILURE is
+// kicked to reconnect immediately instead of waiting out its backoff. The
+// server is not probed: a stock gRPC server rejects frequent pings from idle
+// clients, so keepalives are left to WithDialOptions(grpc.WithKeepaliveParams)
+// for servers that permit them.
+func (c *Client) connection() (*grpc.ClientConn, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn != nil {
+		switch c.conn.GetState() {
+		case connectivity.Shutdown:
+			c.conn = nil
+		case connectivity.TransientFailure:
+			Debugf("Connection to %s is failing, reconnecting\n", c.target)
+			c.conn.ResetConnectBackoff()
+		}
+	}
+	if c.conn == nil {
+		dialOpts := append([]grpc.DialOption{grpc.WithTransportCredentials(c.creds)}, c.dialOpts...)
+		conn, err := grpc.NewClient(c.target, dialOpts...)
+		if err != nil {
+			return nil, &MetricsError{Target: c.target, Err: fmt.Errorf("%w: %v", ErrConnect, err)}
+		}
+		c.conn = conn
+	}
+	return c.conn, nil
+}
+
+// reconnect kicks conn to reconnect immediately after an Unavailable error.
+// The connection is kept rather than closed, since the other RPCs of the same
+// fetch may still be in flight on it. The metric catalog is dropped, since the
+// server may have restarted with a different libtpu.
+func (c *Client) reconnect(conn *grpc.ClientConn) {
+	conn.ResetConnectBackoff()
+	c.InvalidateCatalog()
+}
+
+// Target returns the gRPC target this client talks to.
+func (c *Client) Target() string {
+	return c.target
+}
+
+// Close releases the underlying connection.
+func (c *Client) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.conn == nil {
+		return nil
+	}
+	err := c.conn.Close()
+	c.conn = nil
+	return err
+}
+
+// withTimeout derives the per-fetch context from ctx.
+func (c *Client) withTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.timeout)
+}
+
+// invoke runs rpc on the client's connection honoring the retry policy. metric
+// names the request in the returned *MetricsError.
+func (c *Client) invoke(ctx context.Context, metric string, rpc func(pb.RuntimeMetricServiceClient) error) error {
+	var err error
+	for attempt := 1; ; attempt++ {
+		var conn *grpc.ClientConn
+		conn, err = c.connection()
+		if err != nil {
+			return err
+		}
+		err = rpc(pb.NewRuntimeMetricServiceClient(conn))
+		if err == nil {
+			return nil
+		}
+		if status.Code(err) == codes.Unavailable {
+			c.reconnect(conn)
+		}
+		if attempt >= c.retry.MaxAttempts || !isRetryable(err) {
+			break
+		}
+		Debugf("Retrying %s after attempt %d: %v\n", metric, attempt, err)
+		select {
+		case <-ctx.Done():
+			return &MetricsError{Target: c.target, Metric: metric, Err: ctx.Err()}
+		case <-time.After(c.retry.backoff(attempt)):
+		}
+	}
+	return &MetricsError{Target: c.target, Metric: metric, Err: err}
+}
+
+// getRuntimeMetric issues a single GetRuntimeMetric RPC.
+func (c *Client) getRuntimeMetric(ctx context.Context, name string) (*pb.MetricResponse, error) {
+	req := &pb.MetricRequest{MetricName: name, SkipNodeAggregation: c.skipNodeAggregation}
+	var r *pb.MetricResponse
+	err := c.invoke(ctx, name, func(rpc pb.RuntimeMetricServiceClient) (err error) {
+		r, err = rpc.GetRuntimeMetric(ctx, req)
+		return err
+	})
+	return r, err
+}
+
+// getRuntimeMetrics issues one GetRuntimeMetric RPC per name concurrently and
+// returns the responses in the order of names, or the first error.
+func (c *Client) getRuntimeMetrics(ctx context.Context, names ...string) ([]*pb.MetricResponse, error) {
+	responses := make([]*pb.MetricResponse, len(names))
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			responses[i], errs[i] = c.getRuntimeMetric(ctx, name)
+		}()
+	}
+	wg.Wait()
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+	return responses, nil
+}
@@ -0,0 +1,250 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+// fakeMetricServer serves a fixed number of devices after an artificial
+// per-RPC latency, standing in for the libtpu metrics server.
+type fakeMetricServer struct {
+	pb.UnimplementedRuntimeMetricServiceServer
+	devices int
+	latency time.Duration
+}
+
+func (s *fakeMetricServer) GetRuntimeMetric(ctx context.Context, req *pb.MetricRequest) (*pb.MetricResponse, error) {
+	time.Sleep(s.latency)
+	metrics := make([]*pb.Metric, s.devices)
+	for i := range metrics {
+		gauge := &pb.Gauge{Value: &pb.Gauge_AsInt{AsInt: int64(i)}}
+		if req.GetMetricName() == DUTY_CYCLE_PCT {
+			gauge = &pb.Gauge{Value: &pb.Gauge_AsDouble{AsDouble: 50}}
+		}
+		metrics[i] = &pb.Metric{
+			Attribute: &pb.Attribute{Key: "device-id", Value: &pb.AttrValue{Attr: &pb.AttrValue_IntAttr{IntAttr: int64(i)}}},
+			Measure:   &pb.Metric_Gauge{Gauge: gauge},
+		}
+	}
+	return &pb.MetricResponse{Metric: &pb.TPUMetric{Name: req.GetMetricName(), Metrics: metrics}}, nil
+}
+
+func startFakeMetricServer(tb testing.TB, srv pb.RuntimeMetricServiceServer) string {
+	tb.Helper()
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := grpc.NewServer()
+	pb.RegisterRuntimeMetricServiceServer(s, srv)
+	go s.Serve(lis)
+	tb.Cleanup(s.Stop)
+	return lis.Addr().String()
+}
+
+// flakyMetricServer fails the first RPC with Unavailable while the other RPCs
+// of the same fetch are still in flight.
+type flakyMetricServer struct {
+	fakeMetricServer
+	failed atomic.Bool
+}
+
+func (s *flakyMetricServer) GetRuntimeMetric(ctx context.Context, req *pb.MetricRequest) (*pb.MetricResponse, error) {
+	if s.failed.CompareAndSwap(false, true) {
+		return nil, status.Error(codes.Unavailable, "transient failure")
+	}
+	return s.fakeMetricServer.GetRuntimeMetric(ctx, req)
+}
+
+func TestMetricsRetriesUnavailable(t *testing.T) {
+	srv := &flakyMetricServer{fakeMetricServer: fakeMetricServer{devices: 4, latency: 50 * time.Millisecond}}
+	addr := startFakeMetricServer(t, srv)
+	c, err := NewClient(WithTarget(addr), WithRetry(RetryPolicy{MaxAttempts: 3, InitialBackoff: 10 * time.Millisecond, Multiplier: 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	m, err := c.Metrics(context.Background())
+	if err != nil {
+		t.Fatalf("Metrics() after one Unavailable: %v", err)
+	}
+	if !srv.failed.Load() {
+		t.Fatal("the server never failed an RPC")
+	}
+	if len(m.DeviceIDs) != 4 {
+		t.Errorf("got %d devices, want 4", len(m.DeviceIDs))
+	}
+}
+
+// recordingMetricServer records the last MetricRequest it served.
+type recordingMetricServer struct {
+	fakeMetricServer
+	skipNodeAggregation atomic.Bool
+}
+
+func (s *recordingMetricServer) GetRuntimeMetric(ctx context.Context, req *pb.MetricRequest) (*pb.MetricResponse, error) {
+	s.skipNodeAggregation.Store(req.GetSkipNodeAggregation())
+	return s.fakeMetricServer.GetRuntimeMetric(ctx, req)
+}
+
+func TestWithSkipNodeAggregation(t *testing.T) {
+	srv := &recordingMetricServer{fakeMetricServer: fakeMetricServer{devices: 1}}
+	addr := startFakeMetricServer(t, srv)
+	for _, skip := range []bool{true, false} {
+		c, err := NewClient(WithTarget(addr), WithSkipNodeAggregation(skip))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.MetricByName(context.Background(), MEMORY_USAGE); err != nil {
+			t.Fatal(err)
+		}
+		c.Close()
+		if got := srv.skipNodeAggregation.Load(); got != skip {
+			t.Errorf("skip_node_aggregation = %v, want %v", got, skip)
+		}
+	}
+}
+
+func TestWithPort(t *testing.T) {
+	for _, tt := range []struct {
+		port int
+		want string
+	}{
+		{8432, "localhost:8432"},
+		{0, fmt.Sprintf("localhost:%d", DefaultGRPCPort)},
+		{-1, fmt.Sprintf("localhost:%d", DefaultGRPCPort)},
+	} {
+		c, err := NewClient(WithPort(tt.port))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := c.Target(); got != tt.want {
+			t.Errorf("WithPort(%d): target = %q, want %q", tt.port, got, tt.want)
+		}
+	}
+}
+
+func TestMetricsErrors(t *testing.T) {
+	ctx := context.Background()
+	asMetricsError := func(t *testing.T, err error) *MetricsError {
+		t.Helper()
+		var me *MetricsError
+		if !errors.As(err, &me) {
+			t.Fatalf("error %v (%T) is not a *MetricsError", err, err)
+		}
+		return me
+	}
+
+	t.Run("empty target", func(t *testing.T) {
+		_, err := NewClient(WithTarget(""))
+		asMetricsError(t, err)
+		if !errors.Is(err, ErrConnect) {
+			t.Errorf("NewClient(\"\") = %v, want ErrConnect", err)
+		}
+	})
+	t.Run("bad target", func(t *testing.T) {
+		c, err := NewClient(WithTarget("unix://%zz"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = c.Metrics(ctx)
+		if me := asMetricsError(t, err); me.Target != "unix://%zz" {
+			t.Errorf("Target = %q, want unix://%%zz", me.Target)
+		}
+		if !errors.Is(err, ErrConnect) {
+			t.Errorf("Metrics() = %v, want ErrConnect", err)
+		}
+	})
+	t.Run("status", func(t *testing.T) {
+		c, err := NewClient(WithTarget(startFakeMetricServer(t, &staticMetricServer{})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		_, err = c.MetricByName(ctx, "missing")
+		if me := asMetricsError(t, err); me.Metric != "missing" {
+			t.Errorf("Metric = %q, want missing", me.Metric)
+		}
+		if status.Code(err) != codes.NotFound {
+			t.Errorf("MetricByName() = %v, want NotFound", err)
+		}
+	})
+	t.Run("unavailable", func(t *testing.T) {
+		lis, err := net.Listen("tcp", "localhost:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := lis.Addr().String()
+		lis.Close()
+		c, err := NewClient(WithTarget(addr))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		_, err = c.Metrics(ctx)
+		asMetricsError(t, err)
+		if status.Code(err) != codes.Unavailable {
+			t.Errorf("Metrics() with no server = %v, want Unavailable", err)
+		}
+	})
+	t.Run("invalid", func(t *testing.T) {
+		c, err := NewClient(WithTarget(startFakeMetricServer(t, &fakeMetricServer{devices: 0})))
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer c.Close()
+		_, err = c.Metrics(ctx)
+		asMetricsError(t, err)
+		if !errors.Is(err, ErrInvalidMetrics) || !errors.Is(err, ErrNoDevices) {
+			t.Errorf("Metrics() with no devices = %v, want ErrInvalidMetrics and ErrNoDevices", err)
+		}
+	})
+}
+
+// BenchmarkMetricsDialPerCall reproduces the previous behavior: a new
+// connection and three sequential RPCs per fetch.
+func BenchmarkMetricsDialPerCall(b *testing.B) {
+	addr := startFakeMetricServer(b, &fakeMetricServer{devices: 8, latency: 200 * time.Microsecond})
+	for i := 0; i < b.N; i++ {
+		conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+		if err != nil {
+			b.Fatal(err)
+		}
+		c := pb.NewRuntimeMetricServiceClient(conn)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		for _, name := range []string{MEMORY_USAGE, TOTAL_MEMORY, DUTY_CYCLE_PCT} {
+			if _, err := c.GetRuntimeMetric(ctx, &pb.MetricRequest{MetricName: name}); err != nil {
+				b.Fatal(err)
+			}
+		}
+		cancel()
+		conn.Close()
+	}
+}
+
+// BenchmarkMetricsPersistentClient fetches through a reused connection with
+// the three RPCs in flight concurrently.
+func BenchmarkMetricsPersistentClient(b *testing.B) {
+	addr := startFakeMetricServer(b, &fakeMetricServer{devices: 8, latency: 200 * time.Microsecond})
+	c, err := NewClient(WithTarget(addr))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < b.N; i++ {
+		if _, err := c.Metrics(context.Background()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
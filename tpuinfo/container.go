@@ -0,0 +1,131 @@
+package tpuinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// PidNamespace identifies a pid namespace by the inode of /proc/<pid>/ns/pid.
+// The zero PidNamespace stands for the namespace of the calling process.
+type PidNamespace uint64
+
+func (ns PidNamespace) String() string {
+	return fmt.Sprintf("pid:[%d]", uint64(ns))
+}
+
+// PidNamespaceOf returns the pid namespace of a process.
+func PidNamespaceOf(pid int64) (PidNamespace, error) {
+	link, err := os.Readlink(filepath.Join("/proc", strconv.FormatInt(pid, 10), "ns", "pid"))
+	if err != nil {
+		return 0, err
+	}
+	inode, ok := strings.CutPrefix(link, "pid:[")
+	if !ok || !strings.HasSuffix(inode, "]") {
+		return 0, fmt.Errorf("malformed pid namespace %q of process %d", link, pid)
+	}
+	ino, err := strconv.ParseUint(strings.TrimSuffix(inode, "]"), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("malformed pid namespace %q of process %d: %w", link, pid, err)
+	}
+	return PidNamespace(ino), nil
+}
+
+// parseNSpids parses the NSpid line of /proc/<pid>/status: the pid of the
+// process in each pid namespace from the reader's inward.
+func parseNSpids(line string) []int64 {
+	var pids []int64
+	for _, field := range strings.Fields(line) {
+		pid, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil
+		}
+		pids = append(pids, pid)
+	}
+	return pids
+}
+
+// containerPatterns match the last component of a cgroup path for the
+// cgroup layouts of the common container runtimes, with the systemd
+// (".scope") and the cgroupfs drivers. Podman's conmon monitor runs in a
+// cgroup of its own named after the container.
+var containerPatterns = []struct {
+	runtime string
+	re      *regexp.Regexp
+}{
+	{"docker", regexp.MustCompile(`^docker-([0-9a-f]{64})\.scope$`)},
+	{"containerd", regexp.MustCompile(`^cri-containerd-([0-9a-f]{64})\.scope$`)},
+	{"cri-o", regexp.MustCompile(`^crio-([0-9a-f]{64})(?:\.scope)?$`)},
+	{"podman", regexp.MustCompile(`^libpod-(?:conmon-)?([0-9a-f]{64})(?:\.scope)?$`)},
+}
+
+// bareContainerID is a container id as its own cgroup directory, as created by
+// the cgroupfs driver; the runtime is named by the parent directory, if at all.
+var bareContainerID = regexp.MustCompile(`^[0-9a-f]{64}$`)
+
+// parseContainerID finds the container runtime and id in the contents of
+// /proc/<pid>/cgroup, returning empty strings outside containers. The runtime
+// is empty if the id is known but the runtime is not (e.g. a kubepods cgroup
+// created by the cgroupfs driver).
+func parseContainerID(cgroup string) (runtime, id string) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		// hierarchy-ID:controller-list:cgroup-path
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		dirs := strings.Split(strings.Trim(parts[2], "/"), "/")
+		for i := len(dirs) - 1; i >= 0; i-- {
+			for _, p := range containerPatterns {
+				if m := p.re.FindStringSubmatch(dirs[i]); m != nil {
+					return p.runtime, m[1]
+				}
+			}
+			if bareContainerID.MatchString(dirs[i]) {
+				if i > 0 {
+					switch dirs[i-1] {
+					case "docker":
+						return "docker", dirs[i]
+					case "crio":
+						return "cri-o", dirs[i]
+					}
+				}
+				return "", dirs[i]
+			}
+		}
+	}
+	return "", ""
+}
+
+// readCgroup reads /proc/<pid>/cgroup, "" if it may not be read.
+func readCgroup(pidStr string) string {
+	b, err := os.ReadFile(filepath.Join("/proc", pidStr, "cgroup"))
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// PidIn returns the pid of the process as seen from the pid namespace ns, and
+// false if the process is not visible there. The zero namespace is the one of
+// the calling process.
+func (p *Process) PidIn(ns PidNamespace) (int64, bool) {
+	if ns == 0 {
+		return p.Pid, true
+	}
+	if len(p.NSpids) == 0 {
+		return 0, false
+	}
+	if ns == p.PidNamespace {
+		return p.NSpids[len(p.NSpids)-1], true
+	}
+	// ns must be an ancestor of the namespace of the process
+	depth, ok := pidNamespaceDepth(p.Pid, ns)
+	if !ok || depth >= len(p.NSpids) {
+		return 0, false
+	}
+	return p.NSpids[len(p.NSpids)-1-depth], true
+}
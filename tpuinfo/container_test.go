@@ -0,0 +1,70 @@
+package tpuinfo
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseContainerID(t *testing.T) {
+	id := strings.Repeat("0123456789abcdef", 4)
+	for _, tt := range []struct {
+		name    string
+		cgroup  string
+		runtime string
+		id      string
+	}{
+		{"host v2", "0::/user.slice/user-1000.slice/session-3.scope\n", "", ""},
+		{"host v1", "12:pids:/user.slice\n1:name=systemd:/init.scope\n", "", ""},
+		{"docker v2 systemd", "0::/system.slice/docker-" + id + ".scope\n", "docker", id},
+		{"docker v1 cgroupfs", "12:pids:/docker/" + id + "\n11:memory:/docker/" + id + "\n", "docker", id},
+		{"containerd v2 kubepods", "0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1234.slice/cri-containerd-" + id + ".scope\n", "containerd", id},
+		{"containerd v1 cgroupfs", "4:cpu,cpuacct:/kubepods/besteffort/pod1234/" + id + "\n", "", id},
+		{"cri-o v2 systemd", "0::/kubepods.slice/kubepods-pod1234.slice/crio-" + id + ".scope\n", "cri-o", id},
+		{"cri-o v1 cgroupfs", "3:memory:/crio/" + id + "\n", "cri-o", id},
+		{"podman v2 rootful", "0::/machine.slice/libpod-" + id + ".scope\n", "podman", id},
+		{"podman v2 rootless", "0::/user.slice/user-1000.slice/user@1000.service/user.slice/libpod-" + id + ".scope/container\n", "podman", id},
+		{"podman conmon", "0::/machine.slice/libpod-conmon-" + id + ".scope\n", "podman", id},
+		{"podman v1 cgroupfs", "10:memory:/libpod_parent/libpod-" + id + "\n", "podman", id},
+		{"short id", "0::/system.slice/docker-0123.scope\n", "", ""},
+	} {
+		runtime, got := parseContainerID(tt.cgroup)
+		if runtime != tt.runtime || got != tt.id {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tt.name, runtime, got, tt.runtime, tt.id)
+		}
+	}
+}
+
+func TestParseNSpids(t *testing.T) {
+	for _, tt := range []struct {
+		line string
+		want []int64
+	}{
+		{"1234", []int64{1234}},
+		{"1234\t56\t1", []int64{1234, 56, 1}},
+		{"", nil},
+		{"12 x", nil},
+	} {
+		if got := parseNSpids(tt.line); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseNSpids(%q) = %v, want %v", tt.line, got, tt.want)
+		}
+	}
+}
+
+func TestPidIn(t *testing.T) {
+	const ns PidNamespace = 4026532000
+	p := &Process{Pid: int64(os.Getpid()), NSpids: parseNSpids("1234\t56\t1"), PidNamespace: ns}
+	if pid, ok := p.PidIn(0); !ok || pid != p.Pid {
+		t.Errorf("PidIn(caller) = %d, %v; want %d", pid, ok, p.Pid)
+	}
+	if pid, ok := p.PidIn(ns); !ok || pid != 1 {
+		t.Errorf("PidIn(own namespace) = %d, %v; want 1", pid, ok)
+	}
+	if pid, ok := p.PidIn(ns + 1); ok {
+		t.Errorf("PidIn(unrelated namespace) = %d, want not visible", pid)
+	}
+	if pid, ok := (&Process{Pid: 7}).PidIn(ns); ok {
+		t.Errorf("PidIn without NSpid = %d, want not visible", pid)
+	}
+}
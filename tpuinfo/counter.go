@@ -0,0 +1,136 @@
+package tpuinfo
+
+import (
+	"sync"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+func counterValue(c *pb.Counter) Value {
+	switch v := c.GetValue().(type) {
+	case *pb.Counter_AsDouble:
+		return Value{Kind: KindDouble, Double: v.AsDouble}
+	case *pb.Counter_AsInt:
+		return Value{Kind: KindUint, Uint: v.AsInt}
+	}
+	return Value{}
+}
+
+// Exemplar is an example observation attached to a counter.
+type Exemplar struct {
+	Value     float64
+	Timestamp time.Time
+	Labels    Labels
+}
+
+func newExemplar(e *pb.Exemplar) *Exemplar {
+	if e == nil {
+		return nil
+	}
+	ex := &Exemplar{Value: e.GetValue()}
+	if ts := e.GetTimestamp(); ts != nil {
+		ex.Timestamp = ts.AsTime()
+	}
+	ex.Labels = decodeAttributes(e.GetAttributes()...)
+	return ex
+}
+
+type Quantile struct {
+	Quantile float64
+	Value    float64
+}
+
+// Summary is a decoded Summary measure.
+type Summary struct {
+	Count     uint64
+	Sum       float64
+	Quantiles []Quantile
+}
+
+func newSummary(s *pb.Summary) *Summary {
+	sum := &Summary{Count: s.GetSampleCount(), Sum: s.GetSampleSum()}
+	for _, q := range s.GetQuantile() {
+		sum.Quantiles = append(sum.Quantiles, Quantile{Quantile: q.GetQuantile(), Value: q.GetValue()})
+	}
+	return sum
+}
+
+// Mean is Sum / Count, or 0 without observations.
+func (s *Summary) Mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Sum / float64(s.Count)
+}
+
+// Rate is the per-second increase of one counter series between two samples.
+// Reset is set when the counter went backwards, in which case the rate is
+// computed from zero.
+type Rate struct {
+	Labels    Labels
+	PerSecond float64
+	Interval  time.Duration
+	Reset     bool
+}
+
+type rateState struct {
+	value     float64
+	timestamp time.Time
+}
+
+// RateCalculator turns successive counter samples into per-second rates. The
+// interval between two samples is taken from the Metric timestamps, not the
+// wall clock, so polling jitter does not skew the rate. It is safe for
+// concurrent use.
+type RateCalculator struct {
+	mu   sync.Mutex
+	last map[string]rateState
+}
+
+func NewRateCalculator() *RateCalculator {
+	return &RateCalculator{last: make(map[string]rateState)}
+}
+
+// Observe records one counter sample of metric name and returns its rate
+// since the previous sample of the same series. It returns false for the first
+// sample of a series, for samples without a timestamp or that are not newer
+// than the previous one, and for non-counter samples.
+func (r *RateCalculator) Observe(name string, s Sample) (Rate, bool) {
+	if s.Measure != MeasureCounter || s.Timestamp.IsZero() {
+		return Rate{}, false
+	}
+	key := name + "\x00" + s.LabelString()
+	value := s.Value.Float()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	prev, ok := r.last[key]
+	if ok && !s.Timestamp.After(prev.timestamp) {
+		return Rate{}, false
+	}
+	r.last[key] = rateState{value: value, timestamp: s.Timestamp}
+	if !ok {
+		return Rate{}, false
+	}
+	rate := Rate{Labels: s.Labels, Interval: s.Timestamp.Sub(prev.timestamp)}
+	delta := value - prev.value
+	if delta < 0 {
+		rate.Reset = true
+		delta = value
+	}
+	rate.PerSecond = delta / rate.Interval.Seconds()
+	return rate, true
+}
+
+// Update observes every sample of m and returns the rates that could be
+// computed.
+func (r *RateCalculator) Update(m *RuntimeMetric) []Rate {
+	var rates []Rate
+	for _, s := range m.Samples {
+		if rate, ok := r.Observe(m.Name, s); ok {
+			rates = append(rates, rate)
+		}
+	}
+	return rates
+}
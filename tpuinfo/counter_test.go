@@ -0,0 +1,56 @@
+package tpuinfo
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRateCalculator(t *testing.T) {
+	t0 := time.Unix(1700000000, 0)
+	counter := func(value uint64, at time.Time) Sample {
+		return Sample{
+			Labels:    Labels{{"device-id", "0"}},
+			Timestamp: at,
+			Measure:   MeasureCounter,
+			Value:     Value{Kind: KindUint, Uint: value},
+		}
+	}
+	r := NewRateCalculator()
+	for _, tt := range []struct {
+		name   string
+		sample Sample
+		ok     bool
+		want   Rate
+	}{
+		{"first sample", counter(100, t0), false, Rate{}},
+		{"increase", counter(300, t0.Add(2*time.Second)), true, Rate{PerSecond: 100, Interval: 2 * time.Second}},
+		{"same timestamp", counter(500, t0.Add(2*time.Second)), false, Rate{}},
+		{"older timestamp", counter(500, t0.Add(time.Second)), false, Rate{}},
+		{"reset", counter(40, t0.Add(6*time.Second)), true, Rate{PerSecond: 10, Interval: 4 * time.Second, Reset: true}},
+		{"after reset", counter(60, t0.Add(8*time.Second)), true, Rate{PerSecond: 10, Interval: 2 * time.Second}},
+		{"no timestamp", counter(80, time.Time{}), false, Rate{}},
+		{"gauge", Sample{Timestamp: t0.Add(10 * time.Second), Measure: MeasureGauge, Value: Value{Kind: KindUint, Uint: 1}}, false, Rate{}},
+	} {
+		got, ok := r.Observe("tpu.counter", tt.sample)
+		if ok != tt.ok {
+			t.Errorf("%s: ok = %v, want %v", tt.name, ok, tt.ok)
+			continue
+		}
+		if !ok {
+			continue
+		}
+		if got.PerSecond != tt.want.PerSecond || got.Interval != tt.want.Interval || got.Reset != tt.want.Reset {
+			t.Errorf("%s: rate = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+
+	// series are tracked per metric name and label set
+	if _, ok := r.Observe("tpu.other", counter(1000, t0.Add(20*time.Second))); ok {
+		t.Error("first sample of another metric returned a rate")
+	}
+	other := counter(1000, t0.Add(20*time.Second))
+	other.Labels = Labels{{"device-id", "1"}}
+	if _, ok := r.Observe("tpu.counter", other); ok {
+		t.Error("first sample of another series returned a rate")
+	}
+}
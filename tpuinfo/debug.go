@@ -0,0 +1,36 @@
+package tpuinfo
+
+import (
+	"log"
+	"os"
+	"strconv"
+)
+
+var (
+	DebugEnabled bool        = os.Getenv("LIBTPUINFO_DEBUG") == "1"
+	logger       *log.Logger = log.New(os.Stderr, "[libtpuinfo]: ", log.LstdFlags)
+)
+
+// Debugf logs to stderr when LIBTPUINFO_DEBUG=1 (or DebugEnabled is set).
+func Debugf(format string, args ...interface{}) {
+	if DebugEnabled {
+		logger.Printf(format, args...)
+	}
+}
+
+// DefaultGRPCPort is the port of the TPU runtime metrics server, 8431 unless
+// overridden by LIBTPUINFO_GRPC_PORT.
+var DefaultGRPCPort int = parseDefaultGRPCPort()
+
+func parseDefaultGRPCPort() int {
+	// if not set, attempt to parse the environment variable
+	defaultGRPCPort := 8431
+	if env_port, is_set := os.LookupEnv("LIBTPUINFO_GRPC_PORT"); is_set {
+		Debugf("Found environment variable LIBTPUINFO_GRPC_PORT: %s\n", env_port)
+		if val, err := strconv.ParseInt(env_port, 10, 64); err == nil {
+			Debugf("Parsed environment variable LIBTPUINFO_GRPC_PORT as int\n")
+			defaultGRPCPort = int(val)
+		}
+	}
+	return defaultGRPCPort
+}
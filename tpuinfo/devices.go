@@ -0,0 +1,181 @@
+package tpuinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const googlePCIVendorID = "0x1ae0"
+
+// pciDevicesDir is the sysfs directory of PCI devices, a variable so that
+// tests can point it at a fake tree.
+var pciDevicesDir = "/sys/bus/pci/devices"
+
+var accelNameRegex = regexp.MustCompile(`^accel\d+$`)
+
+// Device is one TPU chip on the PCI bus.
+type Device struct {
+	// Index is the position of the chip in PCI address order.
+	Index int
+	// BDF is the PCI bus/device/function address, e.g. "0000:00:04.0".
+	BDF               string
+	VendorID          string
+	DeviceID          string
+	SubsystemVendorID string
+	SubsystemID       string
+	Chip              *TpuChip
+	// Driver is the bound kernel driver, e.g. "vfio-pci", or empty if none.
+	Driver string
+	// NUMANode is -1 when the platform does not report one.
+	NUMANode int
+	// LocalCPUList is the kernel cpulist of CPUs local to the device, e.g.
+	// "0-55,112-167".
+	LocalCPUList string
+	// IOMMUGroup is -1 when the device is not in an IOMMU group.
+	IOMMUGroup int
+	// DevPath is the /dev node used to open the chip, resolved from sysfs, or
+	// empty if the chip is not bound to a driver that exposes one.
+	DevPath string
+}
+
+// caching device discovery
+var (
+	cache_mu       sync.Mutex
+	devices_cache  []Device = nil
+	last_refreshed          = time.Now()
+)
+
+const cache_duration = 3 * time.Second
+
+func isCacheValid() bool {
+	return devices_cache != nil && last_refreshed.After(time.Now().Add(-cache_duration))
+}
+
+func updateCache(devices []Device) {
+	devices_cache = devices
+	last_refreshed = time.Now()
+}
+
+func readSysfsID(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(b)), nil
+}
+
+// readSysfsInt reads an integer sysfs attribute, returning def if it is
+// missing or malformed.
+func readSysfsInt(path string, def int) int {
+	s, err := readSysfsID(path)
+	if err != nil {
+		return def
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return def
+	}
+	return v
+}
+
+// readSysfsLinkBase returns the last path element of a sysfs symlink, e.g. the
+// driver name for <device>/driver.
+func readSysfsLinkBase(path string) string {
+	target, err := os.Readlink(path)
+	if err != nil {
+		return ""
+	}
+	return filepath.Base(target)
+}
+
+// resolveDevPath finds the /dev node of a PCI device. A vfio-pci device is
+// opened through its IOMMU group, /dev/vfio/<group>; the TPU kernel driver
+// registers an accel class device, /dev/accelN, under the PCI device.
+func resolveDevPath(pciPath, driver string, iommuGroup int) string {
+	if driver == "vfio-pci" {
+		if iommuGroup < 0 {
+			return ""
+		}
+		return fmt.Sprintf("/dev/vfio/%d", iommuGroup)
+	}
+	for _, pattern := range []string{"accel/accel*", "accel*"} {
+		matches, _ := filepath.Glob(filepath.Join(pciPath, pattern))
+		for _, m := range matches {
+			if name := filepath.Base(m); accelNameRegex.MatchString(name) {
+				return "/dev/" + name
+			}
+		}
+	}
+	return ""
+}
+
+// ListDevices returns every TPU chip on the PCI bus, in PCI address order.
+// Results are cached for a few seconds; the returned slice is the caller's to
+// modify.
+func ListDevices() ([]Device, error) {
+	cache_mu.Lock()
+	defer cache_mu.Unlock()
+	if isCacheValid() {
+		return append([]Device(nil), devices_cache...), nil
+	}
+
+	files, err := filepath.Glob(filepath.Join(pciDevicesDir, "*"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list PCI devices: %w", err)
+	}
+
+	devices := make([]Device, 0)
+	for _, pciPath := range files {
+		vendorID, err := readSysfsID(filepath.Join(pciPath, "vendor"))
+		if err != nil {
+			continue // Skip this device if we can't read the vendor ID
+		}
+		if vendorID != googlePCIVendorID {
+			continue
+		}
+		deviceID, err := readSysfsID(filepath.Join(pciPath, "device"))
+		if err != nil {
+			continue // Skip this device
+		}
+		subsystemID, err := readSysfsID(filepath.Join(pciPath, "subsystem_device"))
+		if err != nil {
+			continue // Skip
+		}
+		chipType := FromPCIDeviceID(deviceID, subsystemID)
+		if chipType == nil {
+			continue
+		}
+		subsystemVendorID, _ := readSysfsID(filepath.Join(pciPath, "subsystem_vendor"))
+		localCPUList, _ := readSysfsID(filepath.Join(pciPath, "local_cpulist"))
+		iommuGroup := -1
+		if group := readSysfsLinkBase(filepath.Join(pciPath, "iommu_group")); group != "" {
+			if v, err := strconv.Atoi(group); err == nil {
+				iommuGroup = v
+			}
+		}
+
+		d := Device{
+			Index:             len(devices),
+			BDF:               filepath.Base(pciPath),
+			VendorID:          vendorID,
+			DeviceID:          deviceID,
+			SubsystemVendorID: subsystemVendorID,
+			SubsystemID:       subsystemID,
+			Chip:              chipType,
+			Driver:            readSysfsLinkBase(filepath.Join(pciPath, "driver")),
+			NUMANode:          readSysfsInt(filepath.Join(pciPath, "numa_node"), -1),
+			LocalCPUList:      localCPUList,
+			IOMMUGroup:        iommuGroup,
+		}
+		d.DevPath = resolveDevPath(pciPath, d.Driver, d.IOMMUGroup)
+		devices = append(devices, d)
+	}
+	updateCache(devices)
+	return append([]Device(nil), devices...), nil
+}
@@ -0,0 +1,141 @@
+package tpuinfo
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+)
+
+func TestListDevicesReturnsCopy(t *testing.T) {
+	cache_mu.Lock()
+	saved, saved_time := devices_cache, last_refreshed
+	updateCache([]Device{{Index: 0, BDF: "0000:00:04.0", DevPath: "/dev/vfio/0"}})
+	cache_mu.Unlock()
+	t.Cleanup(func() {
+		cache_mu.Lock()
+		devices_cache, last_refreshed = saved, saved_time
+		cache_mu.Unlock()
+	})
+
+	devices, err := ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	devices[0].DevPath = "/dev/null"
+	devices, err = ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if devices[0].DevPath != "/dev/vfio/0" {
+		t.Errorf("DevPath = %q after modifying a previous result, want /dev/vfio/0", devices[0].DevPath)
+	}
+}
+
+func TestResolveDevPath(t *testing.T) {
+	// a fake /sys/bus/pci/devices/<bdf> tree per driver
+	mkdirs := func(dirs ...string) string {
+		root := t.TempDir()
+		for _, dir := range dirs {
+			if err := os.MkdirAll(filepath.Join(root, dir), 0o755); err != nil {
+				t.Fatal(err)
+			}
+		}
+		return root
+	}
+	for _, tt := range []struct {
+		name       string
+		pciPath    string
+		driver     string
+		iommuGroup int
+		want       string
+	}{
+		{"vfio-pci", mkdirs(), "vfio-pci", 12, "/dev/vfio/12"},
+		{"vfio-pci without iommu group", mkdirs(), "vfio-pci", -1, ""},
+		{"accel class directory", mkdirs("accel/accel3"), "tpu", 5, "/dev/accel3"},
+		{"accel directly under the device", mkdirs("accel2"), "tpu", -1, "/dev/accel2"},
+		{"not an accel device", mkdirs("accel/accel_ctl", "accelerator"), "tpu", -1, ""},
+		{"unbound", mkdirs(), "", 5, ""},
+	} {
+		if got := resolveDevPath(tt.pciPath, tt.driver, tt.iommuGroup); got != tt.want {
+			t.Errorf("%s: resolveDevPath() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+// fakePCIDevice is one device of a fake /sys/bus/pci/devices tree.
+type fakePCIDevice struct {
+	bdf, vendor, device, subsystem string
+	// iommuGroup is linked as <bdf>/iommu_group if >= 0, with the device
+	// bound to vfio-pci
+	iommuGroup int
+}
+
+// useFakeSysfs points device discovery at a fake sysfs tree holding devices
+// for the rest of the test.
+func useFakeSysfs(t *testing.T, devices ...fakePCIDevice) {
+	t.Helper()
+	root := t.TempDir()
+	write := func(path, value string) {
+		if err := os.WriteFile(path, []byte(value+"\n"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, d := range devices {
+		dir := filepath.Join(root, "devices", d.bdf)
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		write(filepath.Join(dir, "vendor"), d.vendor)
+		write(filepath.Join(dir, "device"), d.device)
+		write(filepath.Join(dir, "subsystem_device"), d.subsystem)
+		write(filepath.Join(dir, "numa_node"), "-1")
+		if d.iommuGroup >= 0 {
+			group := filepath.Join(root, "kernel", "iommu_groups", strconv.Itoa(d.iommuGroup))
+			driver := filepath.Join(root, "drivers", "vfio-pci")
+			for _, target := range []string{group, driver} {
+				if err := os.MkdirAll(target, 0o755); err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := os.Symlink(group, filepath.Join(dir, "iommu_group")); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.Symlink(driver, filepath.Join(dir, "driver")); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cache_mu.Lock()
+	saved_dir, saved, saved_time := pciDevicesDir, devices_cache, last_refreshed
+	pciDevicesDir, devices_cache = filepath.Join(root, "devices"), nil
+	cache_mu.Unlock()
+	t.Cleanup(func() {
+		cache_mu.Lock()
+		pciDevicesDir, devices_cache, last_refreshed = saved_dir, saved, saved_time
+		cache_mu.Unlock()
+	})
+}
+
+func TestListDevicesFakeSysfs(t *testing.T) {
+	useFakeSysfs(t,
+		fakePCIDevice{"0000:00:05.0", googlePCIVendorID, "0x0063", "0x0000", 7},
+		fakePCIDevice{"0000:00:04.0", googlePCIVendorID, "0x0063", "0x0000", 6},
+		// not a Google device, and an unknown Google device
+		fakePCIDevice{"0000:00:03.0", "0x8086", "0x0063", "0x0000", -1},
+		fakePCIDevice{"0000:00:06.0", googlePCIVendorID, "0x9999", "0x0000", -1},
+	)
+	devices, err := ListDevices()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(devices) != 2 {
+		t.Fatalf("ListDevices() = %+v, want 2 chips", devices)
+	}
+	for i, want := range []struct{ bdf, devPath string }{{"0000:00:04.0", "/dev/vfio/6"}, {"0000:00:05.0", "/dev/vfio/7"}} {
+		d := devices[i]
+		if d.Index != i || d.BDF != want.bdf || d.DevPath != want.devPath || d.Driver != "vfio-pci" || d.Chip != &V5E {
+			t.Errorf("devices[%d] = %+v, want %s at %s", i, d, want.bdf, want.devPath)
+		}
+	}
+}
@@ -0,0 +1,4 @@
+// Package tpuinfo discovers the TPU chips on a Google Cloud VM, the processes
+// that own them and their runtime metrics. It is pure Go and backs the
+// libtpuinfo c-shared library.
+package tpuinfo
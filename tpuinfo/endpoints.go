@@ -0,0 +1,358 @@
+package tpuinfo
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNoEndpoints is returned when no process holding a TPU chip exports
+// runtime metrics.
+var ErrNoEndpoints = errors.New("no runtime metrics endpoints found")
+
+// Endpoint is the runtime metrics server of one process holding TPU chips.
+type Endpoint struct {
+	// Target is the gRPC target of the server, e.g. "localhost:8431".
+	Target string
+	Port   int
+	Owner  ProcessID
+	// Chips are the Device.Index of the chips of the owner in its local chip
+	// order: TPU_VISIBLE_CHIPS if set, else the chips it holds, ascending.
+	Chips  []int
+	Bounds ProcessBounds
+	// Listening is true if the owner was seen listening on Port. It is false
+	// when its sockets may not be inspected and Port was taken on trust from
+	// TPU_RUNTIME_METRICS_PORTS.
+	Listening bool
+}
+
+// tcpListenState is TCP_LISTEN in the st column of /proc/net/tcp.
+const tcpListenState = "0A"
+
+// listeningPorts returns the local ports of the listening TCP sockets in the
+// /proc/net/tcp format files of a network namespace, keyed by socket inode.
+func listeningPorts(files ...string) map[uint64]int {
+	ports := make(map[uint64]int)
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			continue
+		}
+		s := bufio.NewScanner(f)
+		s.Scan() // header
+		for s.Scan() {
+			// sl local_address rem_address st tx:rx tr:when retrnsmt uid timeout inode
+			fields := strings.Fields(s.Text())
+			if len(fields) < 10 || fields[3] != tcpListenState {
+				continue
+			}
+			_, port, ok := strings.Cut(fields[1], ":")
+			if !ok {
+				continue
+			}
+			p, err1 := strconv.ParseUint(port, 16, 16)
+			inode, err2 := strconv.ParseUint(fields[9], 10, 64)
+			if err1 != nil || err2 != nil || inode == 0 {
+				continue
+			}
+			ports[inode] = int(p)
+		}
+		f.Close()
+	}
+	return ports
+}
+
+// processListeningPorts returns the TCP ports process pid listens on, read
+// from its socket fds and the tcp tables of its network namespace, and false
+// if its fds may not be read.
+func processListeningPorts(pidStr string) (map[int]bool, bool) {
+	fdDir := filepath.Join("/proc", pidStr, "fd")
+	fds, err := os.ReadDir(fdDir)
+	if err != nil {
+		return nil, false
+	}
+	listening := listeningPorts(filepath.Join("/proc", pidStr, "net", "tcp"), filepath.Join("/proc", pidStr, "net", "tcp6"))
+	ports := make(map[int]bool)
+	for _, fd := range fds {
+		link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+		if err != nil {
+			continue
+		}
+		inode, ok := strings.CutPrefix(link, "socket:[")
+		if !ok {
+			continue
+		}
+		ino, err := strconv.ParseUint(strings.TrimSuffix(inode, "]"), 10, 64)
+		if err != nil {
+			continue
+		}
+		if port, ok := listening[ino]; ok {
+			ports[port] = true
+		}
+	}
+	return ports, true
+}
+
+// parsePorts parses a comma separated port list such as
+// TPU_RUNTIME_METRICS_PORTS="8431,8432".
+func parsePorts(list string) []int {
+	var ports []int
+	for _, field := range strings.Split(list, ",") {
+		if port, err := strconv.Atoi(strings.TrimSpace(field)); err == nil && port > 0 && port < 1<<16 {
+			ports = append(ports, port)
+		}
+	}
+	return ports
+}
+
+// DiscoverEndpoints finds the runtime metrics server of every process holding
+// a TPU chip. The candidate ports of a process are TPU_RUNTIME_METRICS_PORTS
+// from its environment, or DefaultGRPCPort if unset, kept only if the process
+// listens on them. When the sockets of a process may not be inspected its
+// candidates are kept unconfirmed; of several unconfirmed owners of a port the
+// one with the lowest pid is kept. Endpoints are sorted by target.
+func DiscoverEndpoints() ([]Endpoint, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	owners, err := GetChipOwners()
+	if err != nil {
+		return nil, err
+	}
+	chips := make(map[ProcessID][]int)
+	for chip, chip_owners := range owners {
+		for _, o := range chip_owners {
+			chips[o.ID()] = append(chips[o.ID()], chip)
+		}
+	}
+	ids := make([]ProcessID, 0, len(chips))
+	for id := range chips {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i].Pid < ids[j].Pid })
+	byPort := make(map[int]Endpoint)
+	for _, id := range ids {
+		held := chips[id]
+		sort.Ints(held)
+		pidStr := strconv.FormatInt(id.Pid, 10)
+		env := readEnviron(pidStr)
+		candidates := []int{DefaultGRPCPort}
+		if ports, ok := env["TPU_RUNTIME_METRICS_PORTS"]; ok {
+			candidates = parsePorts(ports)
+		}
+		bounds := parseProcessBounds(env)
+		if bounds.VisibleChips != nil {
+			if visible, err := resolveVisibleChips(devices, bounds); err == nil {
+				held = visible
+			} else {
+				Debugf("Ignoring the TPU bounds of process %v: %v\n", id, err)
+			}
+		}
+		listening, inspected := processListeningPorts(pidStr)
+		for _, port := range candidates {
+			if inspected && !listening[port] {
+				continue
+			}
+			if prev, ok := byPort[port]; ok && (prev.Listening || !inspected) {
+				// TPU_RUNTIME_METRICS_PORTS lists the ports of every process
+				// sharing the host; keep the one that listens
+				continue
+			}
+			byPort[port] = Endpoint{
+				Target:    net.JoinHostPort("localhost", strconv.Itoa(port)),
+				Port:      port,
+				Owner:     id,
+				Chips:     held,
+				Bounds:    bounds,
+				Listening: inspected,
+			}
+		}
+	}
+	endpoints := make([]Endpoint, 0, len(byPort))
+	for _, e := range byPort {
+		endpoints = append(endpoints, e)
+	}
+	sort.Slice(endpoints, func(i, j int) bool { return compareNatural(endpoints[i].Target, endpoints[j].Target) < 0 })
+	return endpoints, nil
+}
+
+// endpointClient is a client of a discovered endpoint with the number of
+// fetches using it. A stale client, of an endpoint no longer discovered, is
+// closed once the last of them is done.
+type endpointClient struct {
+	client *Client
+	refs   int
+	stale  bool
+}
+
+// endpoint_clients are the clients of discovered endpoints by target. They are
+// kept apart from SharedClient so that they can be closed once their process
+// goes away.
+var (
+	endpoint_mu      sync.Mutex
+	endpoint_clients = make(map[string]*endpointClient)
+)
+
+// acquireEndpointClient returns the client for an endpoint target, creating
+// it with opts on first use, and a func to call once done with it.
+func acquireEndpointClient(target string, opts ...Option) (*Client, func(), error) {
+	endpoint_mu.Lock()
+	defer endpoint_mu.Unlock()
+	e, ok := endpoint_clients[target]
+	if !ok {
+		c, err := NewClient(append(append([]Option(nil), opts...), WithTarget(target))...)
+		if err != nil {
+			return nil, nil, err
+		}
+		e = &endpointClient{client: c}
+		endpoint_clients[target] = e
+	}
+	e.refs++
+	release := func() {
+		endpoint_mu.Lock()
+		defer endpoint_mu.Unlock()
+		e.refs--
+		if e.refs == 0 && e.stale {
+			e.client.Close()
+		}
+	}
+	return e.client, release, nil
+}
+
+// forgetEndpointClients drops the clients of targets not in keep, closing
+// them now if no fetch is using them or else when the last one is done.
+func forgetEndpointClients(keep []Endpoint) {
+	targets := make(map[string]bool)
+	for _, e := range keep {
+		targets[e.Target] = true
+	}
+	endpoint_mu.Lock()
+	defer endpoint_mu.Unlock()
+	for target, e := range endpoint_clients {
+		if targets[target] {
+			continue
+		}
+		e.stale = true
+		delete(endpoint_clients, target)
+		if e.refs == 0 {
+			e.client.Close()
+		}
+	}
+}
+
+// MergedMetrics fetches the metrics of every endpoint concurrently and merges
+// them by device. Each server numbers only the devices of its own process;
+// they are mapped back to host chips through the endpoint's Chips (see
+// endpointDevices for unconfirmed endpoints), so the merged DeviceIDs are
+// canonical device indexes (see DeviceIndex). A device
+// reported by more than one endpoint is kept from the first. Endpoints that
+// fail are reported in the returned error next to the metrics of the others;
+// the metrics are nil only if every endpoint fails.
+func MergedMetrics(ctx context.Context, endpoints []Endpoint, opts ...Option) (*Metrics, error) {
+	if len(endpoints) == 0 {
+		return nil, ErrNoEndpoints
+	}
+	mapping, err := DeviceIndex()
+	if err != nil {
+		return nil, err
+	}
+	return mergedMetrics(ctx, mapping, endpoints, opts...)
+}
+
+func mergedMetrics(ctx context.Context, mapping []DeviceMapping, endpoints []Endpoint, opts ...Option) (*Metrics, error) {
+	results := make([]*Metrics, len(endpoints))
+	errs := make([]error, len(endpoints))
+	var wg sync.WaitGroup
+	for i, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c, release, err := acquireEndpointClient(e.Target, opts...)
+			if err != nil {
+				errs[i] = err
+				return
+			}
+			defer release()
+			results[i], errs[i] = c.Metrics(ctx)
+		}()
+	}
+	wg.Wait()
+
+	type row struct {
+		device       int
+		memory_usage int64
+		total_memory int64
+		duty_cycle   float64
+	}
+	var rows []row
+	seen := make(map[int]bool)
+	for i, m := range results {
+		if m == nil {
+			continue
+		}
+		devices, err := endpointDevices(mapping, endpoints[i], m.DeviceIDs)
+		if err != nil {
+			errs[i] = &MetricsError{Target: endpoints[i].Target, Err: fmt.Errorf("owner %v: %w", endpoints[i].Owner, err)}
+			continue
+		}
+		for j, device := range devices {
+			if seen[device] {
+				Debugf("Device %d reported by more than one endpoint, ignoring %s\n", device, endpoints[i].Target)
+				continue
+			}
+			seen[device] = true
+			rows = append(rows, row{device, m.MemoryUsage[j], m.TotalMemory[j], m.DutyCyclePct[j]})
+		}
+	}
+	err := errors.Join(errs...)
+	if len(rows) == 0 {
+		return nil, err
+	}
+	sort.Slice(rows, func(i, j int) bool { return rows[i].device < rows[j].device })
+	merged := &Metrics{}
+	for _, r := range rows {
+		merged.DeviceIDs = append(merged.DeviceIDs, r.device)
+		merged.MemoryUsage = append(merged.MemoryUsage, r.memory_usage)
+		merged.TotalMemory = append(merged.TotalMemory, r.total_memory)
+		merged.DutyCyclePct = append(merged.DutyCyclePct, r.duty_cycle)
+	}
+	return merged, err
+}
+
+// endpointDevices maps the device ids reported by an endpoint to canonical
+// device indexes. The owner of an unconfirmed endpoint may not be the process
+// serving its port, so if it reports every device on the host its devices are
+// matched to the canonical index in ascending order rather than placed on the
+// owner's Chips.
+func endpointDevices(mapping []DeviceMapping, e Endpoint, local_ids []int) ([]int, error) {
+	if !e.Listening && len(local_ids) == len(mapping) {
+		devices := make([]int, len(mapping))
+		for i := range mapping {
+			devices[i] = mapping[i].Index
+		}
+		return devices, nil
+	}
+	return localToHost(mapping, e.Chips, local_ids)
+}
+
+// GetDiscoveredMetrics fetches the metrics of every discovered endpoint and
+// merges them by canonical device index, see DiscoverEndpoints and
+// MergedMetrics. The clients of endpoints that are no longer discovered, e.g.
+// of processes that exited, are closed.
+func GetDiscoveredMetrics(ctx context.Context) (*Metrics, error) {
+	endpoints, err := DiscoverEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	forgetEndpointClients(endpoints)
+	return MergedMetrics(ctx, endpoints)
+}
@@ -0,0 +1,157 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestProcessListeningPorts(t *testing.T) {
+	lis, err := net.Listen("tcp", "localhost:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lis.Close()
+	port := lis.Addr().(*net.TCPAddr).Port
+	ports, ok := processListeningPorts(strconv.Itoa(os.Getpid()))
+	if !ok || !ports[port] {
+		t.Errorf("processListeningPorts = %v, %v; want port %d", ports, ok, port)
+	}
+}
+
+func TestMergedMetrics(t *testing.T) {
+	// two chips with two cores each, held by one process each
+	var mapping []DeviceMapping
+	for chip := 0; chip < 2; chip++ {
+		for core := 0; core < 2; core++ {
+			mapping = append(mapping, DeviceMapping{Index: len(mapping), Chip: chip, Core: core, RuntimeDeviceID: -1})
+		}
+	}
+	endpoints := []Endpoint{
+		{Target: startFakeMetricServer(t, &fakeMetricServer{devices: 2}), Chips: []int{1}},
+		{Target: startFakeMetricServer(t, &fakeMetricServer{devices: 2}), Chips: []int{0}},
+		// holds no chips, so its devices can not be placed
+		{Target: startFakeMetricServer(t, &fakeMetricServer{devices: 2})},
+	}
+	m, err := mergedMetrics(context.Background(), mapping, endpoints)
+	if err == nil {
+		t.Errorf("expected an error for the endpoint without chips")
+	}
+	if m == nil {
+		t.Fatalf("no metrics: %v", err)
+	}
+	if !reflect.DeepEqual(m.DeviceIDs, []int{0, 1, 2, 3}) {
+		t.Errorf("DeviceIDs = %v, want [0 1 2 3]", m.DeviceIDs)
+	}
+	// the fake servers report each device's id as its memory usage
+	if !reflect.DeepEqual(m.MemoryUsage, []int64{0, 1, 0, 1}) {
+		t.Errorf("MemoryUsage = %v, want [0 1 0 1]", m.MemoryUsage)
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	if got := parsePorts("8431, 8432,x,0,70000"); !reflect.DeepEqual(got, []int{8431, 8432}) {
+		t.Errorf("parsePorts = %v", got)
+	}
+}
+
+func TestLocalToHost(t *testing.T) {
+	// four single-core chips
+	var mapping []DeviceMapping
+	for chip := 0; chip < 4; chip++ {
+		mapping = append(mapping, DeviceMapping{Index: chip, Chip: chip, RuntimeDeviceID: -1})
+	}
+	devices := []Device{{Index: 0, DevPath: "/dev/accel0"}, {Index: 1, DevPath: "/dev/accel1"}, {Index: 2, DevPath: "/dev/accel2"}, {Index: 3, DevPath: "/dev/accel3"}}
+	b := parseProcessBounds(map[string]string{
+		"TPU_VISIBLE_CHIPS":            "3,2",
+		"TPU_CHIPS_PER_PROCESS_BOUNDS": "1,2,1",
+		"TPU_PROCESS_BOUNDS":           "2,1,1",
+	})
+	if b.ChipsPerProcess() != 2 || b.Processes() != 2 {
+		t.Errorf("bounds %+v: %d chips per process, %d processes", b, b.ChipsPerProcess(), b.Processes())
+	}
+	chips, err := resolveVisibleChips(devices, b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	host, err := localToHost(mapping, chips, []int{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(host, []int{3, 2}) {
+		t.Errorf("localToHost = %v, want [3 2]", host)
+	}
+
+	b.ChipsPerProcessBounds = []int{1, 1, 1}
+	if _, err := resolveVisibleChips(devices, b); err == nil {
+		t.Errorf("expected an error for bounds disagreeing with TPU_VISIBLE_CHIPS")
+	}
+}
+
+func TestMergedMetricsUnconfirmedEndpoint(t *testing.T) {
+	// two single-device chips; the owner of the port was seen holding only
+	// chip 1, but the server behind the port reports both devices
+	mapping := []DeviceMapping{{Index: 0, Chip: 0, RuntimeDeviceID: -1}, {Index: 1, Chip: 1, RuntimeDeviceID: -1}}
+	target := startFakeMetricServer(t, &fakeMetricServer{devices: 2})
+	t.Cleanup(func() { forgetEndpointClients(nil) })
+	m, err := mergedMetrics(context.Background(), mapping, []Endpoint{{Target: target, Chips: []int{1}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.DeviceIDs, []int{0, 1}) {
+		t.Errorf("DeviceIDs = %v, want [0 1]", m.DeviceIDs)
+	}
+	// a confirmed endpoint is placed on the chips of its owner
+	if _, err := mergedMetrics(context.Background(), mapping, []Endpoint{{Target: target, Chips: []int{1}, Listening: true}}); !errors.Is(err, ErrDeviceIndexMismatch) {
+		t.Errorf("2 devices of a listening owner of 1 chip: got %v, want ErrDeviceIndexMismatch", err)
+	}
+}
+
+func TestForgetEndpointClients(t *testing.T) {
+	target := startFakeMetricServer(t, &fakeMetricServer{devices: 1})
+	t.Cleanup(func() { forgetEndpointClients(nil) })
+	kept, release, err := acquireEndpointClient("localhost:1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	release()
+	if again, release, _ := acquireEndpointClient("localhost:1"); again != kept {
+		t.Error("acquireEndpointClient created a second client for the same target")
+	} else {
+		release()
+	}
+
+	// a client still in use is closed only once released
+	busy, release, err := acquireEndpointClient(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := busy.Metrics(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	forgetEndpointClients([]Endpoint{{Target: "localhost:1"}})
+	if again, release, _ := acquireEndpointClient("localhost:1"); again != kept {
+		t.Error("forgetEndpointClients dropped the client of a discovered endpoint")
+	} else {
+		release()
+	}
+	if _, err := busy.Metrics(context.Background()); err != nil {
+		t.Errorf("Metrics() on a forgotten client still in use: %v", err)
+	}
+	release()
+	busy.mu.Lock()
+	closed := busy.conn == nil
+	busy.mu.Unlock()
+	if !closed {
+		t.Error("the forgotten client was not closed once released")
+	}
+	if again, release, _ := acquireEndpointClient(target); again == busy {
+		t.Error("forgetEndpointClients kept the client of an endpoint no longer discovered")
+	} else {
+		release()
+	}
+}
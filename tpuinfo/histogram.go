@@ -0,0 +1,230 @@
+package tpuinfo
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+// Runtime latency distributions, in microseconds.
+const (
+	HOST_TO_DEVICE_TRANSFER_LATENCY = "megascale.host_to_device_transfer_latencies.microsecond.cumulative.distribution"
+	DEVICE_TO_HOST_TRANSFER_LATENCY = "megascale.device_to_host_transfer_latencies.microsecond.cumulative.distribution"
+	BUFFER_TRANSFER_LATENCY         = "megascale.dcn_transfer_latencies.microsecond.cumulative.distribution"
+	COLLECTIVE_E2E_LATENCY          = "megascale.collective_end_to_end_latencies.microsecond.cumulative.distribution"
+)
+
+// LatencyMetrics are the latency distributions reported by LatencyHistograms,
+// keyed by a short name.
+var LatencyMetrics = map[string]string{
+	"host_to_device": HOST_TO_DEVICE_TRANSFER_LATENCY,
+	"device_to_host": DEVICE_TO_HOST_TRANSFER_LATENCY,
+	"buffer":         BUFFER_TRANSFER_LATENCY,
+	"collective_e2e": COLLECTIVE_E2E_LATENCY,
+}
+
+// Histogram is a decoded Distribution. Bucket 0 is the underflow bucket
+// (-inf, Bounds[0]), bucket i is [Bounds[i-1], Bounds[i]) and the last bucket
+// is the overflow bucket [Bounds[len(Bounds)-1], +inf), so there is always one
+// more count than bounds.
+type Histogram struct {
+	Count                 int64
+	Mean                  float64
+	Min                   float64
+	Max                   float64
+	SumOfSquaredDeviation float64
+	Bounds                []float64
+	Counts                []int64
+}
+
+// maxFiniteBuckets bounds NumFiniteBuckets of linear and exponential buckets,
+// whose bounds are generated rather than sent, so that a bad response can't
+// make us allocate gigabytes.
+const maxFiniteBuckets = 1 << 16
+
+// bucketBounds resolves the finite bucket boundaries of every BucketOptions
+// variant.
+func bucketBounds(o *pb.Distribution_BucketOptions) ([]float64, error) {
+	switch opts := o.GetOptions().(type) {
+	case nil:
+		return nil, nil
+	case *pb.Distribution_BucketOptions_LinearBuckets:
+		l := opts.LinearBuckets
+		if l.GetNumFiniteBuckets() < 0 || l.GetNumFiniteBuckets() > maxFiniteBuckets || l.GetWidth() <= 0 {
+			return nil, fmt.Errorf("invalid linear buckets: %v", l)
+		}
+		bounds := make([]float64, l.GetNumFiniteBuckets()+1)
+		for i := range bounds {
+			bounds[i] = l.GetOffset() + l.GetWidth()*float64(i)
+		}
+		return bounds, nil
+	case *pb.Distribution_BucketOptions_ExponentialBuckets:
+		e := opts.ExponentialBuckets
+		if e.GetNumFiniteBuckets() < 0 || e.GetNumFiniteBuckets() > maxFiniteBuckets || e.GetGrowthFactor() <= 1 || e.GetScale() <= 0 {
+			return nil, fmt.Errorf("invalid exponential buckets: %v", e)
+		}
+		bounds := make([]float64, e.GetNumFiniteBuckets()+1)
+		for i := range bounds {
+			bounds[i] = e.GetScale() * math.Pow(e.GetGrowthFactor(), float64(i))
+		}
+		return bounds, nil
+	case *pb.Distribution_BucketOptions_ExplicitBuckets:
+		bounds := opts.ExplicitBuckets.GetBounds()
+		if len(bounds) == 0 || !sort.Float64sAreSorted(bounds) {
+			return nil, fmt.Errorf("invalid explicit buckets: %v", bounds)
+		}
+		return append([]float64(nil), bounds...), nil
+	case *pb.Distribution_BucketOptions_RegularBuckets:
+		// A single bound describes linear buckets, several bounds describe
+		// consecutive buckets each starting offset after the previous one.
+		r := opts.RegularBuckets
+		if len(r.GetBounds()) == 1 {
+			b := r.GetBounds()[0]
+			return bucketBounds(&pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_LinearBuckets{
+				LinearBuckets: &pb.Distribution_BucketOptions_Linear{NumFiniteBuckets: r.GetNumFiniteBuckets(), Width: b.GetWidth(), Offset: b.GetOffset()},
+			}})
+		}
+		var bounds []float64
+		edge := 0.0
+		for i, b := range r.GetBounds() {
+			lower := edge + b.GetOffset()
+			if i == 0 || lower != edge {
+				bounds = append(bounds, lower)
+			}
+			edge = lower + b.GetWidth()
+			bounds = append(bounds, edge)
+		}
+		if !sort.Float64sAreSorted(bounds) {
+			return nil, fmt.Errorf("invalid regular buckets: %v", r)
+		}
+		return bounds, nil
+	}
+	return nil, fmt.Errorf("unsupported bucket options %T", o.GetOptions())
+}
+
+func newHistogram(d *pb.Distribution) (*Histogram, error) {
+	bounds, err := bucketBounds(d.GetBucketOptions())
+	if err != nil {
+		return nil, err
+	}
+	counts := d.GetBucketCounts()
+	if len(counts) > len(bounds)+1 {
+		return nil, fmt.Errorf("%d bucket counts for %d buckets", len(counts), len(bounds)+1)
+	}
+	// trailing empty buckets may be omitted
+	h := &Histogram{
+		Count:                 d.GetCount(),
+		Mean:                  d.GetMean(),
+		Min:                   d.GetMin(),
+		Max:                   d.GetMax(),
+		SumOfSquaredDeviation: d.GetSumOfSquaredDeviation(),
+		Bounds:                bounds,
+		Counts:                make([]int64, len(bounds)+1),
+	}
+	copy(h.Counts, counts)
+	return h, nil
+}
+
+// StdDev is the population standard deviation of the observed values.
+func (h *Histogram) StdDev() float64 {
+	if h.Count <= 0 {
+		return 0
+	}
+	return math.Sqrt(h.SumOfSquaredDeviation / float64(h.Count))
+}
+
+// bucketRange returns the value range of bucket i, clamped to [Min, Max] when
+// those are reported so the underflow and overflow buckets are finite.
+func (h *Histogram) bucketRange(i int) (float64, float64) {
+	lower, upper := math.Inf(-1), math.Inf(1)
+	if i > 0 {
+		lower = h.Bounds[i-1]
+	}
+	if i < len(h.Bounds) {
+		upper = h.Bounds[i]
+	}
+	if h.Max > h.Min {
+		lower, upper = math.Max(lower, h.Min), math.Min(upper, h.Max)
+	}
+	switch {
+	case math.IsInf(lower, 0) && math.IsInf(upper, 0):
+		return h.Mean, h.Mean
+	case math.IsInf(lower, 0):
+		lower = upper
+	case math.IsInf(upper, 0):
+		upper = lower
+	}
+	if upper < lower {
+		upper = lower
+	}
+	return lower, upper
+}
+
+// Quantile estimates the q-th quantile (0 <= q <= 1) by interpolating linearly
+// inside the bucket that holds it. It returns NaN for an empty histogram.
+func (h *Histogram) Quantile(q float64) float64 {
+	total := int64(0)
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total <= 0 {
+		return math.NaN()
+	}
+	q = math.Max(0, math.Min(1, q))
+	rank := q * float64(total)
+	cumulative := 0.0
+	for i, c := range h.Counts {
+		if c <= 0 {
+			continue
+		}
+		if cumulative+float64(c) >= rank {
+			lower, upper := h.bucketRange(i)
+			return lower + (upper-lower)*(rank-cumulative)/float64(c)
+		}
+		cumulative += float64(c)
+	}
+	_, upper := h.bucketRange(len(h.Counts) - 1)
+	return upper
+}
+
+func (h *Histogram) P50() float64 { return h.Quantile(0.50) }
+func (h *Histogram) P90() float64 { return h.Quantile(0.90) }
+func (h *Histogram) P99() float64 { return h.Quantile(0.99) }
+
+// LatencyHistograms fetches the runtime latency distributions in
+// LatencyMetrics, keyed by short name. Metrics the running libtpu does not
+// serve are left out; an error is returned only if none could be fetched.
+func (c *Client) LatencyHistograms(ctx context.Context) (map[string]*RuntimeMetric, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	var (
+		mu      sync.Mutex
+		wg      sync.WaitGroup
+		lastErr error
+	)
+	result := make(map[string]*RuntimeMetric)
+	for short, name := range LatencyMetrics {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r, err := c.getRuntimeMetric(ctx, name)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				Debugf("Could not get %s: %v\n", name, err)
+				lastErr = err
+				return
+			}
+			result[short] = decodeRuntimeMetric(r)
+		}()
+	}
+	wg.Wait()
+	if len(result) == 0 && lastErr != nil {
+		return nil, lastErr
+	}
+	return result, nil
+}
@@ -0,0 +1,118 @@
+package tpuinfo
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+func TestBucketBounds(t *testing.T) {
+	linear := func(n int32, width, offset float64) *pb.Distribution_BucketOptions {
+		return &pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_LinearBuckets{
+			LinearBuckets: &pb.Distribution_BucketOptions_Linear{NumFiniteBuckets: n, Width: width, Offset: offset},
+		}}
+	}
+	exponential := func(n int32, growth, scale float64) *pb.Distribution_BucketOptions {
+		return &pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_ExponentialBuckets{
+			ExponentialBuckets: &pb.Distribution_BucketOptions_Exponential{NumFiniteBuckets: n, GrowthFactor: growth, Scale: scale},
+		}}
+	}
+	explicit := func(bounds ...float64) *pb.Distribution_BucketOptions {
+		return &pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_ExplicitBuckets{
+			ExplicitBuckets: &pb.Distribution_BucketOptions_Explicit{Bounds: bounds},
+		}}
+	}
+	regular := func(n int32, bounds ...*pb.Distribution_BucketOptions_Bound) *pb.Distribution_BucketOptions {
+		return &pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_RegularBuckets{
+			RegularBuckets: &pb.Distribution_BucketOptions_Regular{NumFiniteBuckets: n, Bounds: bounds},
+		}}
+	}
+	bound := func(width, offset float64) *pb.Distribution_BucketOptions_Bound {
+		return &pb.Distribution_BucketOptions_Bound{Width: width, Offset: offset}
+	}
+	for _, tt := range []struct {
+		name    string
+		options *pb.Distribution_BucketOptions
+		want    []float64
+		wantErr bool
+	}{
+		{"none", nil, nil, false},
+		{"linear", linear(3, 10, 5), []float64{5, 15, 25, 35}, false},
+		{"linear zero width", linear(3, 0, 0), nil, true},
+		{"linear too many buckets", linear(math.MaxInt32, 1, 0), nil, true},
+		{"exponential", exponential(3, 2, 1), []float64{1, 2, 4, 8}, false},
+		{"exponential no growth", exponential(3, 1, 1), nil, true},
+		{"exponential too many buckets", exponential(math.MaxInt32, 2, 1), nil, true},
+		{"explicit", explicit(1, 5, 10), []float64{1, 5, 10}, false},
+		{"explicit empty", explicit(), nil, true},
+		{"explicit unsorted", explicit(5, 1), nil, true},
+		{"regular linear", regular(2, bound(5, 1)), []float64{1, 6, 11}, false},
+		{"regular consecutive", regular(2, bound(1, 0), bound(2, 0)), []float64{0, 1, 3}, false},
+		{"regular with gap", regular(2, bound(1, 0), bound(2, 1)), []float64{0, 1, 2, 4}, false},
+	} {
+		got, err := bucketBounds(tt.options)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, want error %v", tt.name, err, tt.wantErr)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: bounds = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewHistogramPadsCounts(t *testing.T) {
+	d := &pb.Distribution{
+		Count: 3,
+		BucketOptions: &pb.Distribution_BucketOptions{Options: &pb.Distribution_BucketOptions_ExplicitBuckets{
+			ExplicitBuckets: &pb.Distribution_BucketOptions_Explicit{Bounds: []float64{1, 2}},
+		}},
+		BucketCounts: []int64{1, 2},
+	}
+	h, err := newHistogram(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int64{1, 2, 0}; !reflect.DeepEqual(h.Counts, want) {
+		t.Errorf("counts = %v, want %v", h.Counts, want)
+	}
+	d.BucketCounts = []int64{1, 2, 3, 4}
+	if _, err := newHistogram(d); err == nil {
+		t.Error("4 counts for 3 buckets: want an error")
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		h    Histogram
+		q    float64
+		want float64
+	}{
+		{"median on a bound", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 0.5, 10},
+		{"inside the first bucket", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 0.25, 5},
+		{"inside the second bucket", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 0.75, 15},
+		{"minimum", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 0, 0},
+		{"maximum", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 1, 20},
+		{"q clamped", Histogram{Bounds: []float64{0, 10, 20}, Counts: []int64{0, 10, 10, 0}}, 2, 20},
+		{"underflow clamped to min", Histogram{Min: 2, Max: 8, Bounds: []float64{10, 20}, Counts: []int64{4, 0, 0}}, 0.5, 5},
+		{"overflow clamped to max", Histogram{Min: 12, Max: 16, Bounds: []float64{10}, Counts: []int64{0, 2}}, 0.5, 14},
+		{"overflow without max", Histogram{Bounds: []float64{10}, Counts: []int64{0, 2}}, 0.5, 10},
+		{"no bounds", Histogram{Mean: 7, Counts: []int64{3}}, 0.9, 7},
+		{"empty", Histogram{Bounds: []float64{10}, Counts: []int64{0, 0}}, 0.5, math.NaN()},
+		{"no counts", Histogram{}, 0.5, math.NaN()},
+	} {
+		got := tt.h.Quantile(tt.q)
+		if math.IsNaN(tt.want) {
+			if !math.IsNaN(got) {
+				t.Errorf("%s: Quantile(%v) = %v, want NaN", tt.name, tt.q, got)
+			}
+			continue
+		}
+		if math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: Quantile(%v) = %v, want %v", tt.name, tt.q, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// ProcessID identifies a process across pid reuse: the pid together with its
+// start time in clock ticks since boot (field 22 of /proc/<pid>/stat). A
+// recycled pid has a later start time.
+type ProcessID struct {
+	Pid        int64
+	StartTicks uint64
+}
+
+func (id ProcessID) String() string {
+	return fmt.Sprintf("%d@%d", id.Pid, id.StartTicks)
+}
+
+// ErrProcessGone is returned when a process exited or its pid was reused.
+var ErrProcessGone = errors.New("process is gone")
+
+// readProcessID reads the identity of the process currently running as pid.
+func readProcessID(pid int64) (ProcessID, error) {
+	stat, err := statFields(strconv.FormatInt(pid, 10))
+	if err != nil {
+		return ProcessID{}, err
+	}
+	start, err := strconv.ParseUint(stat[19], 10, 64)
+	if err != nil {
+		return ProcessID{}, fmt.Errorf("malformed /proc/%d/stat: %w", pid, err)
+	}
+	return ProcessID{Pid: pid, StartTicks: start}, nil
+}
+
+// Verify reports whether id still names the same running process, i.e. the
+// process has not exited and its pid has not been reused.
+func (id ProcessID) Verify() (bool, error) {
+	current, err := readProcessID(id.Pid)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return current == id, nil
+}
+
+// Open returns a pidfd for the process, verified to refer to id. Signals sent
+// and waits done through the pidfd can not reach a later process reusing the
+// pid. It returns ErrProcessGone if the process is no longer id and
+// errors.ErrUnsupported on kernels without pidfd_open (before Linux 5.3).
+func (id ProcessID) Open() (*os.File, error) {
+	f, err := pidfdOpen(id.Pid)
+	if err != nil {
+		return nil, err
+	}
+	// the pidfd pins the process it was opened for, so a match after opening
+	// means it refers to id
+	ok, err := id.Verify()
+	if err != nil || !ok {
+		f.Close()
+		if err == nil {
+			err = fmt.Errorf("%w: %v", ErrProcessGone, id)
+		}
+		return nil, err
+	}
+	return f, nil
+}
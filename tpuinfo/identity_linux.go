@@ -0,0 +1,32 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+func pidfdOpen(pid int64) (*os.File, error) {
+	fd, err := unix.PidfdOpen(int(pid), 0)
+	if err != nil {
+		switch {
+		case errors.Is(err, unix.ENOSYS):
+			return nil, errors.ErrUnsupported
+		case errors.Is(err, unix.ESRCH):
+			return nil, fmt.Errorf("%w: pid %d", ErrProcessGone, pid)
+		}
+		return nil, fmt.Errorf("pidfd_open %d: %w", pid, err)
+	}
+	return os.NewFile(uintptr(fd), fmt.Sprintf("pidfd:%d", pid)), nil
+}
+
+// fileID returns the device and inode of a file.
+func fileID(fi os.FileInfo) (dev, ino uint64) {
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok {
+		return st.Dev, st.Ino
+	}
+	return 0, 0
+}
@@ -0,0 +1,16 @@
+//go:build !linux
+
+package tpuinfo
+
+import (
+	"errors"
+	"os"
+)
+
+func pidfdOpen(pid int64) (*os.File, error) {
+	return nil, errors.ErrUnsupported
+}
+
+func fileID(fi os.FileInfo) (dev, ino uint64) {
+	return 0, 0
+}
@@ -0,0 +1,43 @@
+package tpuinfo
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestProcessIDVerify(t *testing.T) {
+	id, err := readProcessID(int64(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := id.Verify(); err != nil || !ok {
+		t.Errorf("Verify() of this process = %v, %v, want true", ok, err)
+	}
+	reused := ProcessID{Pid: id.Pid, StartTicks: id.StartTicks + 1}
+	if ok, err := reused.Verify(); err != nil || ok {
+		t.Errorf("Verify() with a later start time = %v, %v, want false", ok, err)
+	}
+}
+
+func TestProcessIDOpen(t *testing.T) {
+	id, err := readProcessID(int64(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := id.Open()
+	if errors.Is(err, errors.ErrUnsupported) {
+		t.Skip("pidfd_open is not supported")
+	}
+	if err != nil {
+		t.Fatalf("Open() of this process: %v", err)
+	}
+	f.Close()
+	reused := ProcessID{Pid: id.Pid, StartTicks: id.StartTicks + 1}
+	if f, err := reused.Open(); !errors.Is(err, ErrProcessGone) {
+		if f != nil {
+			f.Close()
+		}
+		t.Errorf("Open() with a later start time = %v, want ErrProcessGone", err)
+	}
+}
@@ -0,0 +1,104 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// DeviceMapping is one row of the canonical device index. Every per-device
+// API (tpu_pids, tpu_metrics, GetMetrics, Client.DeviceMetrics, the CLI
+// tables) reports devices in this order: chips in PCI address order, each expanded into its
+// DevicesPerChip cores, matched to runtime device ids in ascending order.
+type DeviceMapping struct {
+	// Index is the canonical device index.
+	Index int
+	// Chip is the Device.Index of the chip holding this device and Core the
+	// core within that chip.
+	Chip int
+	Core int
+	BDF  string
+	// DevPath is the /dev node of the chip.
+	DevPath string
+	// RuntimeDeviceID is the device id reported by the runtime metrics, or -1
+	// when it is not known.
+	RuntimeDeviceID int
+}
+
+// ErrDeviceIndexMismatch is returned when the runtime reports a different
+// number of devices than discovered on the PCI bus.
+var ErrDeviceIndexMismatch = errors.New("runtime devices do not match the discovered chips")
+
+// DeviceIndex returns the canonical device index from PCI discovery alone,
+// with RuntimeDeviceID set to -1.
+func DeviceIndex() ([]DeviceMapping, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	mapping := make([]DeviceMapping, 0, len(devices))
+	for _, d := range devices {
+		for core := 0; core < d.Chip.Value.DevicesPerChip; core++ {
+			mapping = append(mapping, DeviceMapping{
+				Index:           len(mapping),
+				Chip:            d.Index,
+				Core:            core,
+				BDF:             d.BDF,
+				DevPath:         d.DevPath,
+				RuntimeDeviceID: -1,
+			})
+		}
+	}
+	return mapping, nil
+}
+
+// assignRuntimeIDs fills RuntimeDeviceID from the device ids the runtime
+// reports, matching the i-th smallest id to canonical index i.
+func assignRuntimeIDs(mapping []DeviceMapping, runtime_ids []int) error {
+	if len(runtime_ids) != len(mapping) {
+		return fmt.Errorf("%w: %d runtime devices, %d discovered", ErrDeviceIndexMismatch, len(runtime_ids), len(mapping))
+	}
+	ids := append([]int(nil), runtime_ids...)
+	sort.Ints(ids)
+	for i := range mapping {
+		mapping[i].RuntimeDeviceID = ids[i]
+	}
+	return nil
+}
+
+// CanonicalMetrics renumbers metrics fetched from a single metrics server,
+// whose DeviceIDs are runtime device ids in ascending order, to canonical
+// device indexes, and fills RuntimeDeviceID in mapping on the way.
+func CanonicalMetrics(mapping []DeviceMapping, m *Metrics) error {
+	if err := assignRuntimeIDs(mapping, m.DeviceIDs); err != nil {
+		return err
+	}
+	for i := range mapping {
+		m.DeviceIDs[i] = mapping[i].Index
+	}
+	return nil
+}
+
+// DeviceIndex returns the canonical device index with the runtime device ids
+// reported by this client's metrics server.
+func (c *Client) DeviceIndex(ctx context.Context) ([]DeviceMapping, error) {
+	mapping, err := DeviceIndex()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	r, err := c.getRuntimeMetric(ctx, MEMORY_USAGE)
+	if err != nil {
+		return nil, err
+	}
+	runtime_ids, _, err := deviceValues(r, Value.Int64)
+	if err != nil {
+		return nil, &MetricsError{Target: c.target, Metric: MEMORY_USAGE, Err: err}
+	}
+	if err := assignRuntimeIDs(mapping, runtime_ids); err != nil {
+		return nil, &MetricsError{Target: c.target, Err: err}
+	}
+	return mapping, nil
+}
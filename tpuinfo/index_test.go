@@ -0,0 +1,69 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestCanonicalMetrics(t *testing.T) {
+	mapping := []DeviceMapping{
+		{Index: 0, Chip: 0, RuntimeDeviceID: -1},
+		{Index: 1, Chip: 1, RuntimeDeviceID: -1},
+		{Index: 2, Chip: 2, RuntimeDeviceID: -1},
+	}
+	m := &Metrics{DeviceIDs: []int{4, 5, 6}, MemoryUsage: []int64{1, 2, 3}}
+	if err := CanonicalMetrics(mapping, m); err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{0, 1, 2}; !reflect.DeepEqual(m.DeviceIDs, want) {
+		t.Errorf("DeviceIDs = %v, want %v", m.DeviceIDs, want)
+	}
+	if mapping[2].RuntimeDeviceID != 6 {
+		t.Errorf("RuntimeDeviceID of index 2 = %d, want 6", mapping[2].RuntimeDeviceID)
+	}
+
+	short := &Metrics{DeviceIDs: []int{0, 1}}
+	if err := CanonicalMetrics(mapping, short); !errors.Is(err, ErrDeviceIndexMismatch) {
+		t.Errorf("2 devices for 3: got %v, want ErrDeviceIndexMismatch", err)
+	}
+}
+
+func TestDeviceMetrics(t *testing.T) {
+	c, err := NewClient(WithTarget(startFakeMetricServer(t, &fakeMetricServer{devices: 2})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	ctx := context.Background()
+
+	// no chips discovered: the runtime device ids are kept
+	useFakeSysfs(t)
+	m, err := c.DeviceMetrics(ctx)
+	if err != nil {
+		t.Fatalf("DeviceMetrics() without chips: %v", err)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(m.DeviceIDs, want) {
+		t.Errorf("DeviceIDs = %v, want %v", m.DeviceIDs, want)
+	}
+
+	// one single-device chip for two runtime devices
+	useFakeSysfs(t, fakePCIDevice{"0000:00:04.0", googlePCIVendorID, "0x0063", "0x0000", -1})
+	_, err = c.DeviceMetrics(ctx)
+	var me *MetricsError
+	if !errors.As(err, &me) || !errors.Is(err, ErrDeviceIndexMismatch) {
+		t.Errorf("DeviceMetrics() with one chip = %v, want a *MetricsError wrapping ErrDeviceIndexMismatch", err)
+	}
+
+	useFakeSysfs(t,
+		fakePCIDevice{"0000:00:04.0", googlePCIVendorID, "0x0063", "0x0000", -1},
+		fakePCIDevice{"0000:00:05.0", googlePCIVendorID, "0x0063", "0x0000", -1},
+	)
+	if m, err = c.DeviceMetrics(ctx); err != nil {
+		t.Fatalf("DeviceMetrics() with two chips: %v", err)
+	}
+	if want := []int{0, 1}; !reflect.DeepEqual(m.DeviceIDs, want) {
+		t.Errorf("DeviceIDs = %v, want %v", m.DeviceIDs, want)
+	}
+}
@@ -0,0 +1,166 @@
+package tpuinfo
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	podpb "github.com/rdyro/libtpuinfo/podresources_proto"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+const (
+	// DefaultPodResourcesSocket is where the kubelet serves the PodResources
+	// API.
+	DefaultPodResourcesSocket = "/var/lib/kubelet/pod-resources/kubelet.sock"
+	// TPUResourceName is the extended resource the GKE TPU device plugin
+	// allocates chips as.
+	TPUResourceName = "google.com/tpu"
+)
+
+// podCgroupRegex matches the pod directory of a kubepods cgroup path with the
+// cgroupfs ("pod<uid>") and the systemd ("kubepods-burstable-pod<uid>.slice",
+// dashes in the uid replaced by underscores) drivers.
+var podCgroupRegex = regexp.MustCompile(`^(?:kubepods-(?:(?:burstable|besteffort)-)?)?pod([0-9a-f]{8}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{4}[-_][0-9a-f]{12})(?:\.slice)?$`)
+
+// parsePodCgroup finds the pod UID and QoS class ("guaranteed", "burstable"
+// or "besteffort") in the contents of /proc/<pid>/cgroup, returning empty
+// strings for processes outside of kubepods.
+func parsePodCgroup(cgroup string) (uid, qos string) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || !strings.Contains(parts[2], "kubepods") {
+			continue
+		}
+		qos = "guaranteed"
+		for _, dir := range strings.Split(strings.Trim(parts[2], "/"), "/") {
+			switch {
+			case dir == "burstable" || dir == "kubepods-burstable.slice":
+				qos = "burstable"
+			case dir == "besteffort" || dir == "kubepods-besteffort.slice":
+				qos = "besteffort"
+			}
+			if m := podCgroupRegex.FindStringSubmatch(dir); m != nil {
+				return strings.ReplaceAll(m[1], "_", "-"), qos
+			}
+		}
+	}
+	return "", ""
+}
+
+// Labels returns the container, pod and Slurm job of the process as labels:
+// container_id, container_runtime, pod_uid, qos_class, slurm_job_id and
+// slurm_step_id, each only when known.
+func (p *Process) Labels() Labels {
+	labels := appendKnown(nil,
+		Label{"container_id", p.ContainerID},
+		Label{"container_runtime", p.ContainerRuntime},
+		Label{"pod_uid", p.PodUID},
+		Label{"qos_class", p.PodQOSClass},
+	)
+	return append(labels, p.slurmLabels()...)
+}
+
+// PodDevices are the devices of one resource the kubelet allocated to a
+// container.
+type PodDevices struct {
+	Namespace string
+	Pod       string
+	Container string
+	Resource  string
+	DeviceIDs []string
+}
+
+// Labels returns the namespace, pod and container as labels.
+func (d PodDevices) Labels() Labels {
+	return Labels{{"namespace", d.Namespace}, {"pod", d.Pod}, {"container", d.Container}}
+}
+
+// ListPodResources queries the kubelet PodResources API on the unix socket
+// (DefaultPodResourcesSocket if empty) for the devices allocated to every
+// container on the node. The query is bounded by DefaultTimeout unless ctx has
+// an earlier deadline.
+func ListPodResources(ctx context.Context, socket string) ([]PodDevices, error) {
+	if socket == "" {
+		socket = DefaultPodResourcesSocket
+	}
+	conn, err := grpc.NewClient("unix://"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, fmt.Errorf("kubelet pod resources %s: %w", socket, err)
+	}
+	defer conn.Close()
+	ctx, cancel := context.WithTimeout(ctx, DefaultTimeout)
+	defer cancel()
+	r, err := podpb.NewPodResourcesListerClient(conn).List(ctx, &podpb.ListPodResourcesRequest{})
+	if err != nil {
+		return nil, fmt.Errorf("kubelet pod resources %s: %w", socket, err)
+	}
+	var allocs []PodDevices
+	for _, pod := range r.GetPodResources() {
+		for _, container := range pod.GetContainers() {
+			for _, devices := range container.GetDevices() {
+				allocs = append(allocs, PodDevices{
+					Namespace: pod.GetNamespace(),
+					Pod:       pod.GetName(),
+					Container: container.GetName(),
+					Resource:  devices.GetResourceName(),
+					DeviceIDs: devices.GetDeviceIds(),
+				})
+			}
+		}
+	}
+	return allocs, nil
+}
+
+// deviceIDMatches reports whether a device plugin device id names the chip:
+// its Index, PCI address, /dev node or the base name of the /dev node.
+func deviceIDMatches(d Device, id string) bool {
+	if id == strconv.Itoa(d.Index) || id == d.BDF {
+		return true
+	}
+	return d.DevPath != "" && (id == d.DevPath || id == filepath.Base(d.DevPath))
+}
+
+// ChipPods assigns the TPUResourceName allocations to chips, returning the
+// allocation holding each chip by chip Index. Chips not allocated to any
+// container are absent.
+func ChipPods(devices []Device, allocs []PodDevices) map[int]PodDevices {
+	byIndex := make(map[int]PodDevices)
+	for _, a := range allocs {
+		if a.Resource != TPUResourceName {
+			continue
+		}
+		for _, id := range a.DeviceIDs {
+			matched := false
+			for _, d := range devices {
+				if deviceIDMatches(d, id) {
+					byIndex[d.Index] = a
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				Debugf("%s device %q of %s/%s is not a discovered TPU chip\n", a.Resource, id, a.Namespace, a.Pod)
+			}
+		}
+	}
+	return byIndex
+}
+
+// GetChipPods returns the container the kubelet allocated each chip to, by
+// chip Index, querying the PodResources API on socket (see ListPodResources).
+func GetChipPods(ctx context.Context, socket string) (map[int]PodDevices, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	allocs, err := ListPodResources(ctx, socket)
+	if err != nil {
+		return nil, err
+	}
+	return ChipPods(devices, allocs), nil
+}
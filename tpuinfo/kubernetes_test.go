@@ -0,0 +1,96 @@
+package tpuinfo
+
+import (
+	"context"
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	podpb "github.com/rdyro/libtpuinfo/podresources_proto"
+	"google.golang.org/grpc"
+)
+
+// fakePodResourcesServer stands in for the kubelet PodResources API.
+type fakePodResourcesServer struct {
+	podpb.UnimplementedPodResourcesListerServer
+	pods []*podpb.PodResources
+}
+
+func (s *fakePodResourcesServer) List(ctx context.Context, req *podpb.ListPodResourcesRequest) (*podpb.ListPodResourcesResponse, error) {
+	return &podpb.ListPodResourcesResponse{PodResources: s.pods}, nil
+}
+
+func startFakePodResourcesServer(tb testing.TB, srv podpb.PodResourcesListerServer) string {
+	tb.Helper()
+	socket := filepath.Join(tb.TempDir(), "kubelet.sock")
+	lis, err := net.Listen("unix", socket)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	s := grpc.NewServer()
+	podpb.RegisterPodResourcesListerServer(s, srv)
+	go s.Serve(lis)
+	tb.Cleanup(s.Stop)
+	return socket
+}
+
+func TestChipPods(t *testing.T) {
+	socket := startFakePodResourcesServer(t, &fakePodResourcesServer{pods: []*podpb.PodResources{
+		{Name: "train-0", Namespace: "ml", Containers: []*podpb.ContainerResources{
+			{Name: "jax", Devices: []*podpb.ContainerDevices{
+				{ResourceName: TPUResourceName, DeviceIds: []string{"0", "accel1"}},
+				{ResourceName: "nvidia.com/gpu", DeviceIds: []string{"2"}},
+			}},
+			{Name: "sidecar"},
+		}},
+		{Name: "serve-0", Namespace: "default", Containers: []*podpb.ContainerResources{
+			{Name: "server", Devices: []*podpb.ContainerDevices{{ResourceName: TPUResourceName, DeviceIds: []string{"0000:00:07.0"}}}},
+		}},
+	}})
+	allocs, err := ListPodResources(context.Background(), socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(allocs) != 3 {
+		t.Fatalf("got %d allocations, want 3: %+v", len(allocs), allocs)
+	}
+
+	devices := []Device{
+		{Index: 0, BDF: "0000:00:04.0", DevPath: "/dev/accel0"},
+		{Index: 1, BDF: "0000:00:05.0", DevPath: "/dev/accel1"},
+		{Index: 2, BDF: "0000:00:06.0", DevPath: "/dev/accel2"},
+		{Index: 3, BDF: "0000:00:07.0", DevPath: "/dev/accel3"},
+	}
+	pods := ChipPods(devices, allocs)
+	want := map[int]string{0: "ml=train-0=jax", 1: "ml=train-0=jax", 3: "default=serve-0=server"}
+	got := make(map[int]string)
+	for chip, p := range pods {
+		got[chip] = p.Namespace + "=" + p.Pod + "=" + p.Container
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ChipPods = %v, want %v", got, want)
+	}
+	if s := pods[3].Labels().String(); s != "namespace=default,pod=serve-0,container=server" {
+		t.Errorf("Labels = %q", s)
+	}
+}
+
+func TestParsePodCgroup(t *testing.T) {
+	for _, tc := range []struct {
+		cgroup, uid, qos string
+	}{
+		{"0::/kubepods.slice/kubepods-burstable.slice/kubepods-burstable-pod1b4e28ba_2fa1_11d2_883f_0016d3cca427.slice/cri-containerd-0123.scope",
+			"1b4e28ba-2fa1-11d2-883f-0016d3cca427", "burstable"},
+		{"0::/kubepods.slice/kubepods-pod1b4e28ba_2fa1_11d2_883f_0016d3cca427.slice/cri-containerd-0123.scope",
+			"1b4e28ba-2fa1-11d2-883f-0016d3cca427", "guaranteed"},
+		{"11:memory:/kubepods/besteffort/pod1b4e28ba-2fa1-11d2-883f-0016d3cca427/0123",
+			"1b4e28ba-2fa1-11d2-883f-0016d3cca427", "besteffort"},
+		{"0::/system.slice/docker-0123.scope", "", ""},
+	} {
+		uid, qos := parsePodCgroup(tc.cgroup)
+		if uid != tc.uid || qos != tc.qos {
+			t.Errorf("parsePodCgroup(%q) = %q, %q, want %q, %q", tc.cgroup, uid, qos, tc.uid, tc.qos)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package tpuinfo
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+// Label is one decoded metric attribute.
+type Label struct {
+	Key   string
+	Value string
+}
+
+// Labels is the label set of a sample. Nested attributes are flattened:
+// a KeyValueList contributes "key.subkey" labels and an array "key.0",
+// "key.1", ... labels.
+type Labels []Label
+
+// String formats the labels as "key=value,key2=value2".
+func (l Labels) String() string {
+	parts := make([]string, len(l))
+	for i, label := range l {
+		parts[i] = label.Key + "=" + label.Value
+	}
+	return strings.Join(parts, ",")
+}
+
+// Get returns the value of the label with the given key.
+func (l Labels) Get(key string) (string, bool) {
+	for _, label := range l {
+		if label.Key == key {
+			return label.Value, true
+		}
+	}
+	return "", false
+}
+
+// appendKnown appends the labels with a non-empty value.
+func appendKnown(labels Labels, known ...Label) Labels {
+	for _, label := range known {
+		if label.Value != "" {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// compareNatural compares two strings, ordering runs of digits numerically so
+// that "2" sorts before "10" and "/dev/vfio/2" before "/dev/vfio/10". Strings
+// that only differ in how equal numbers are written, e.g. "01" and "1", are
+// ordered bytewise so that they do not compare equal.
+func compareNatural(a, b string) int {
+	origA, origB := a, b
+	for a != "" && b != "" {
+		ad, bd := digitPrefix(a), digitPrefix(b)
+		if ad > 0 && bd > 0 {
+			an, _ := strconv.ParseUint(a[:ad], 10, 64)
+			bn, _ := strconv.ParseUint(b[:bd], 10, 64)
+			if an != bn {
+				if an < bn {
+					return -1
+				}
+				return 1
+			}
+			a, b = a[ad:], b[bd:]
+			continue
+		}
+		if a[0] != b[0] {
+			if a[0] < b[0] {
+				return -1
+			}
+			return 1
+		}
+		a, b = a[1:], b[1:]
+	}
+	if c := len(a) - len(b); c != 0 {
+		return c
+	}
+	return strings.Compare(origA, origB)
+}
+
+func digitPrefix(s string) int {
+	i := 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		i++
+	}
+	return i
+}
+
+// Compare orders label sets key by key, comparing values naturally.
+func (l Labels) Compare(other Labels) int {
+	for i := 0; i < len(l) && i < len(other); i++ {
+		if c := strings.Compare(l[i].Key, other[i].Key); c != 0 {
+			return c
+		}
+		if c := compareNatural(l[i].Value, other[i].Value); c != 0 {
+			return c
+		}
+	}
+	return len(l) - len(other)
+}
+
+// deviceIDKeys are the attribute keys the runtime uses for chip/core ids.
+var deviceIDKeys = []string{"device-id", "device_id", "chip-id", "chip_id", "core-id", "core_id"}
+
+// deviceID returns the chip/core id of a label set: the first label with a
+// well-known device id key, or the only label if it is an integer.
+func (l Labels) deviceID() (int64, bool) {
+	for _, key := range deviceIDKeys {
+		if v, ok := l.Get(key); ok {
+			if id, err := strconv.ParseInt(v, 10, 64); err == nil {
+				return id, true
+			}
+		}
+	}
+	if len(l) == 1 {
+		if id, err := strconv.ParseInt(l[0].Value, 10, 64); err == nil {
+			return id, true
+		}
+	}
+	return 0, false
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+	if key == "" {
+		return prefix
+	}
+	return prefix + "." + key
+}
+
+// appendAttrValue decodes every AttrValue variant into labels under key.
+func appendAttrValue(labels Labels, key string, v *pb.AttrValue) Labels {
+	switch a := v.GetAttr().(type) {
+	case *pb.AttrValue_StringAttr:
+		return append(labels, Label{key, a.StringAttr})
+	case *pb.AttrValue_BoolAttr:
+		return append(labels, Label{key, strconv.FormatBool(a.BoolAttr)})
+	case *pb.AttrValue_IntAttr:
+		return append(labels, Label{key, strconv.FormatInt(a.IntAttr, 10)})
+	case *pb.AttrValue_DoubleAttr:
+		return append(labels, Label{key, strconv.FormatFloat(a.DoubleAttr, 'g', -1, 64)})
+	case *pb.AttrValue_BytesAttr:
+		return append(labels, Label{key, hex.EncodeToString(a.BytesAttr)})
+	case *pb.AttrValue_ArrayAttr:
+		for i, elem := range a.ArrayAttr.GetAttrs() {
+			labels = appendAttrValue(labels, joinKey(key, strconv.Itoa(i)), elem)
+		}
+		return labels
+	case *pb.AttrValue_KvlistAttr:
+		for _, attr := range a.KvlistAttr.GetAttributes() {
+			labels = appendAttrValue(labels, joinKey(key, attr.GetKey()), attr.GetValue())
+		}
+		return labels
+	}
+	// an empty AttrValue still records the key
+	return append(labels, Label{key, ""})
+}
+
+func decodeAttributes(attrs ...*pb.Attribute) Labels {
+	var labels Labels
+	for _, attr := range attrs {
+		if attr == nil {
+			continue
+		}
+		labels = appendAttrValue(labels, attr.GetKey(), attr.GetValue())
+	}
+	return labels
+}
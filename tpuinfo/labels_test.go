@@ -0,0 +1,95 @@
+package tpuinfo
+
+import (
+	"reflect"
+	"testing"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+func sign(n int) int {
+	switch {
+	case n < 0:
+		return -1
+	case n > 0:
+		return 1
+	}
+	return 0
+}
+
+func TestCompareNatural(t *testing.T) {
+	for _, tt := range []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1},
+		{"10", "2", 1},
+		{"/dev/vfio/2", "/dev/vfio/10", -1},
+		{"accel9", "accel10", -1},
+		{"a", "b", -1},
+		{"core", "core1", -1},
+		{"1", "1", 0},
+		{"", "", 0},
+		{"01", "1", -1},
+		{"1", "01", 1},
+		{"chip01a", "chip1b", -1},
+	} {
+		if got := sign(compareNatural(tt.a, tt.b)); got != tt.want {
+			t.Errorf("compareNatural(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestLabelsCompare(t *testing.T) {
+	for _, tt := range []struct {
+		a, b Labels
+		want int
+	}{
+		{Labels{{"core", "2"}}, Labels{{"core", "10"}}, -1},
+		{Labels{{"core", "01"}}, Labels{{"core", "1"}}, -1},
+		{Labels{{"chip", "9"}}, Labels{{"core", "0"}}, -1},
+		{Labels{{"core", "1"}}, Labels{{"core", "1"}, {"host", "a"}}, -1},
+		{Labels{{"core", "1"}, {"host", "a"}}, Labels{{"core", "1"}, {"host", "a"}}, 0},
+		{nil, nil, 0},
+	} {
+		if got := sign(tt.a.Compare(tt.b)); got != tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+		if got := sign(tt.b.Compare(tt.a)); got != -tt.want {
+			t.Errorf("%v.Compare(%v) = %d, want %d", tt.b, tt.a, got, -tt.want)
+		}
+	}
+}
+
+func TestAppendAttrValue(t *testing.T) {
+	str := func(s string) *pb.AttrValue { return &pb.AttrValue{Attr: &pb.AttrValue_StringAttr{StringAttr: s}} }
+	for _, tt := range []struct {
+		name  string
+		value *pb.AttrValue
+		want  Labels
+	}{
+		{"string", str("v5e"), Labels{{"k", "v5e"}}},
+		{"bool", &pb.AttrValue{Attr: &pb.AttrValue_BoolAttr{BoolAttr: true}}, Labels{{"k", "true"}}},
+		{"int", &pb.AttrValue{Attr: &pb.AttrValue_IntAttr{IntAttr: -3}}, Labels{{"k", "-3"}}},
+		{"double", &pb.AttrValue{Attr: &pb.AttrValue_DoubleAttr{DoubleAttr: 0.25}}, Labels{{"k", "0.25"}}},
+		{"bytes", &pb.AttrValue{Attr: &pb.AttrValue_BytesAttr{BytesAttr: []byte{0xde, 0xad}}}, Labels{{"k", "dead"}}},
+		{"array", &pb.AttrValue{Attr: &pb.AttrValue_ArrayAttr{ArrayAttr: &pb.ArrayAttrValue{
+			Attrs: []*pb.AttrValue{str("a"), str("b")},
+		}}}, Labels{{"k.0", "a"}, {"k.1", "b"}}},
+		{"kvlist", &pb.AttrValue{Attr: &pb.AttrValue_KvlistAttr{KvlistAttr: &pb.KeyValueList{
+			Attributes: []*pb.Attribute{{Key: "chip", Value: str("0")}, {Key: "core", Value: str("1")}},
+		}}}, Labels{{"k.chip", "0"}, {"k.core", "1"}}},
+		{"nested", &pb.AttrValue{Attr: &pb.AttrValue_KvlistAttr{KvlistAttr: &pb.KeyValueList{
+			Attributes: []*pb.Attribute{{Key: "ids", Value: &pb.AttrValue{Attr: &pb.AttrValue_ArrayAttr{ArrayAttr: &pb.ArrayAttrValue{
+				Attrs: []*pb.AttrValue{str("7")},
+			}}}}},
+		}}}, Labels{{"k.ids.0", "7"}}},
+		{"empty array", &pb.AttrValue{Attr: &pb.AttrValue_ArrayAttr{ArrayAttr: &pb.ArrayAttrValue{}}}, nil},
+		{"unset", &pb.AttrValue{}, Labels{{"k", ""}}},
+		{"nil", nil, Labels{{"k", ""}}},
+	} {
+		if got := appendAttrValue(nil, "k", tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
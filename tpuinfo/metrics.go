@@ -0,0 +1,83 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+)
+
+const (
+	TOTAL_MEMORY   = "tpu.runtime.hbm.memory.total.bytes"
+	MEMORY_USAGE   = "tpu.runtime.hbm.memory.usage.bytes"
+	DUTY_CYCLE_PCT = "tpu.runtime.tensorcore.dutycycle.percent"
+)
+
+var (
+	// ErrConnect is wrapped when a gRPC client for the metrics server cannot be
+	// created.
+	ErrConnect = errors.New("could not connect to the TPU metrics gRPC server")
+	// ErrMetricLengths is wrapped, with ErrInvalidMetrics, when the per-device
+	// metric responses do not describe the same number of devices.
+	ErrMetricLengths = errors.New("lengths of metrics do not agree")
+)
+
+type Metrics struct {
+	DeviceIDs    []int
+	MemoryUsage  []int64
+	TotalMemory  []int64
+	DutyCyclePct []float64
+}
+
+// GetMetrics fetches memory and duty cycle metrics from the default local
+// runtime metrics server (LIBTPUINFO_GRPC_PORT or 8431), keyed by canonical
+// device index, see Client.DeviceMetrics.
+func GetMetrics(ctx context.Context) (*Metrics, error) {
+	c, err := SharedClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.DeviceMetrics(ctx)
+}
+
+// Metrics fetches memory and duty cycle metrics for every device the server
+// reports. DeviceIDs are the server's own runtime device ids; DeviceMetrics
+// renumbers them to canonical device indexes.
+func (c *Client) Metrics(ctx context.Context) (*Metrics, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+
+	// get the metrics, all under the same deadline
+	rs, err := c.getRuntimeMetrics(ctx, MEMORY_USAGE, TOTAL_MEMORY, DUTY_CYCLE_PCT)
+	if err != nil {
+		return nil, err
+	}
+	metrics, err := normalizeMetrics(rs[0], rs[1], rs[2])
+	if err != nil {
+		return nil, &MetricsError{Target: c.target, Err: err}
+	}
+	return metrics, nil
+}
+
+// DeviceMetrics fetches Metrics and renumbers them to canonical device indexes
+// (see DeviceIndex). When no chips are discovered on the PCI bus, e.g. in a
+// container without /sys/bus/pci, the runtime device ids are kept. Otherwise
+// the server must report every device on the host; it fails with
+// ErrDeviceIndexMismatch when it serves only the chips of its own process, in
+// which case GetDiscoveredMetrics merges the servers of every process.
+func (c *Client) DeviceMetrics(ctx context.Context) (*Metrics, error) {
+	mapping, err := DeviceIndex()
+	if err != nil {
+		return nil, &MetricsError{Target: c.target, Err: err}
+	}
+	metrics, err := c.Metrics(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(mapping) == 0 {
+		Debugf("No TPU chips discovered, keeping the runtime device ids of %s\n", c.target)
+		return metrics, nil
+	}
+	if err := CanonicalMetrics(mapping, metrics); err != nil {
+		return nil, &MetricsError{Target: c.target, Err: err}
+	}
+	return metrics, nil
+}
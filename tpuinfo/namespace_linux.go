@@ -0,0 +1,39 @@
+package tpuinfo
+
+import (
+	"path/filepath"
+	"strconv"
+
+	"golang.org/x/sys/unix"
+)
+
+// nsGetParent is the NS_GET_PARENT ioctl, _IO(0xb7, 0x2).
+const nsGetParent = 0xb702
+
+// pidNamespaceDepth returns how many levels the pid namespace ns is above the
+// one of process pid, walking up with NS_GET_PARENT (Linux 4.9+). The walk
+// stops at the namespace of the calling process.
+func pidNamespaceDepth(pid int64, ns PidNamespace) (int, bool) {
+	fd, err := unix.Open(filepath.Join("/proc", strconv.FormatInt(pid, 10), "ns", "pid"), unix.O_RDONLY|unix.O_CLOEXEC, 0)
+	if err != nil {
+		return 0, false
+	}
+	for depth := 0; ; depth++ {
+		var st unix.Stat_t
+		if err := unix.Fstat(fd, &st); err != nil {
+			unix.Close(fd)
+			return 0, false
+		}
+		if PidNamespace(st.Ino) == ns {
+			unix.Close(fd)
+			return depth, true
+		}
+		parent, err := unix.IoctlRetInt(fd, nsGetParent)
+		unix.Close(fd)
+		if err != nil {
+			// EPERM once we reach the namespace of the calling process
+			return 0, false
+		}
+		fd = parent
+	}
+}
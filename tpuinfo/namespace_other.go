@@ -0,0 +1,7 @@
+//go:build !linux
+
+package tpuinfo
+
+func pidNamespaceDepth(pid int64, ns PidNamespace) (int, bool) {
+	return 0, false
+}
@@ -0,0 +1,103 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+var (
+	// ErrInvalidMetrics is wrapped by every validation failure of the metric
+	// responses, together with one of the more specific errors below.
+	ErrInvalidMetrics  = errors.New("invalid metric response")
+	ErrNoDevices       = errors.New("no device samples")
+	ErrMissingDeviceID = errors.New("sample without a device id")
+	ErrDuplicateDevice = errors.New("duplicate device id")
+	ErrMissingDevice   = errors.New("missing device id")
+	ErrUnevenCores     = errors.New("cores do not divide evenly across chips")
+)
+
+func invalidMetrics(reason error, format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %w: %s", ErrInvalidMetrics, reason, fmt.Sprintf(format, args...))
+}
+
+// deviceValues extracts one value per device id from a per-device metric and
+// returns the sorted ids.
+func deviceValues[T any](r *pb.MetricResponse, get_value func(v Value) T) ([]int, map[int]T, error) {
+	metric := decodeRuntimeMetric(r)
+	values := make(map[int]T)
+	ids := make([]int, 0, len(metric.Samples))
+	for _, s := range metric.Samples {
+		id, ok := s.DeviceID()
+		if !ok {
+			return nil, nil, invalidMetrics(ErrMissingDeviceID, "%s sample %q", metric.Name, s.LabelString())
+		}
+		if _, seen := values[int(id)]; seen {
+			return nil, nil, invalidMetrics(ErrDuplicateDevice, "%s reports device %d more than once", metric.Name, id)
+		}
+		values[int(id)] = get_value(s.Value)
+		ids = append(ids, int(id))
+	}
+	sort.Ints(ids)
+	return ids, values, nil
+}
+
+// normalizeMetrics validates the memory usage, total memory and duty cycle
+// responses and combines them into one per-core Metrics.
+//
+// Memory is reported per core and duty cycle per chip. Core ids must be the
+// same in both memory metrics, the number of cores must be a multiple of the
+// number of chips, and core id c belongs to chip id c / cores_per_chip.
+func normalizeMetrics(memory_usage_r, total_memory_r, duty_cycle_r *pb.MetricResponse) (*Metrics, error) {
+	device_ids, memory_usage, err := deviceValues(memory_usage_r, Value.Int64)
+	if err != nil {
+		return nil, err
+	}
+	total_ids, total_memory, err := deviceValues(total_memory_r, Value.Int64)
+	if err != nil {
+		return nil, err
+	}
+	chip_ids, duty_cycle_pct, err := deviceValues(duty_cycle_r, Value.Float)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(device_ids) == 0 {
+		return nil, invalidMetrics(ErrNoDevices, "%s is empty", MEMORY_USAGE)
+	}
+	if len(chip_ids) == 0 {
+		return nil, invalidMetrics(ErrNoDevices, "%s is empty", DUTY_CYCLE_PCT)
+	}
+	if len(total_ids) != len(device_ids) {
+		return nil, invalidMetrics(ErrMetricLengths, "len(total_memory) = %d; len(memory_usage) = %d", len(total_ids), len(device_ids))
+	}
+	for _, id := range device_ids {
+		if _, ok := total_memory[id]; !ok {
+			return nil, invalidMetrics(ErrMissingDevice, "%s has no device %d", TOTAL_MEMORY, id)
+		}
+	}
+	if len(device_ids)%len(chip_ids) != 0 {
+		return nil, invalidMetrics(ErrUnevenCores, "%d cores for %d chips", len(device_ids), len(chip_ids))
+	}
+	cores_per_chip := len(device_ids) / len(chip_ids)
+
+	m := &Metrics{
+		DeviceIDs:    device_ids,
+		MemoryUsage:  make([]int64, len(device_ids)),
+		TotalMemory:  make([]int64, len(device_ids)),
+		DutyCyclePct: make([]float64, len(device_ids)),
+	}
+	for i, id := range device_ids {
+		chip_id := id / cores_per_chip
+		duty, ok := duty_cycle_pct[chip_id]
+		if !ok {
+			return nil, invalidMetrics(ErrMissingDevice, "%s has no chip %d for core %d", DUTY_CYCLE_PCT, chip_id, id)
+		}
+		m.MemoryUsage[i] = memory_usage[id]
+		m.TotalMemory[i] = total_memory[id]
+		m.DutyCyclePct[i] = duty
+	}
+	return m, nil
+}
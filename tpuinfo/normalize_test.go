@@ -0,0 +1,51 @@
+package tpuinfo
+
+import (
+	"context"
+	"testing"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+	"google.golang.org/protobuf/proto"
+)
+
+func FuzzNormalizeMetrics(f *testing.F) {
+	for _, devices := range []int{0, 1, 4, 8} {
+		srv := &fakeMetricServer{devices: devices}
+		var seed [3][]byte
+		for i, name := range []string{MEMORY_USAGE, TOTAL_MEMORY, DUTY_CYCLE_PCT} {
+			r, _ := srv.GetRuntimeMetric(context.Background(), &pb.MetricRequest{MetricName: name})
+			seed[i], _ = proto.Marshal(r)
+		}
+		f.Add(seed[0], seed[1], seed[2])
+		// duty cycle per chip with two cores per chip
+		half, _ := (&fakeMetricServer{devices: devices / 2}).GetRuntimeMetric(context.Background(), &pb.MetricRequest{MetricName: DUTY_CYCLE_PCT})
+		b, _ := proto.Marshal(half)
+		f.Add(seed[0], seed[1], b)
+	}
+
+	f.Fuzz(func(t *testing.T, memory_usage, total_memory, duty_cycle []byte) {
+		var rs [3]*pb.MetricResponse
+		for i, b := range [][]byte{memory_usage, total_memory, duty_cycle} {
+			rs[i] = &pb.MetricResponse{}
+			if err := proto.Unmarshal(b, rs[i]); err != nil {
+				t.Skip()
+			}
+		}
+		m, err := normalizeMetrics(rs[0], rs[1], rs[2])
+		if err != nil {
+			return
+		}
+		n := len(m.DeviceIDs)
+		if n == 0 {
+			t.Fatalf("no devices without an error")
+		}
+		if len(m.MemoryUsage) != n || len(m.TotalMemory) != n || len(m.DutyCyclePct) != n {
+			t.Fatalf("mismatched lengths: %+v", m)
+		}
+		for i := 1; i < n; i++ {
+			if m.DeviceIDs[i] <= m.DeviceIDs[i-1] {
+				t.Fatalf("device ids not strictly increasing: %v", m.DeviceIDs)
+			}
+		}
+	})
+}
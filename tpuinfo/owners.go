@@ -0,0 +1,245 @@
+package tpuinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	tpuDeviceRegex = regexp.MustCompile(`^/dev/(?:accel|vfio/)\d+$`)
+)
+
+// open(2) access modes, the low bits of OpenFile.Flags
+const (
+	oACCMODE = 0x3
+	oRDONLY  = 0x0
+	oWRONLY  = 0x1
+	oRDWR    = 0x2
+)
+
+// OpenFile is one file descriptor a process holds on a TPU /dev node.
+type OpenFile struct {
+	FD int
+	// Flags are the open(2) flags from /proc/<pid>/fdinfo/<fd>, -1 if they
+	// could not be read.
+	Flags int
+}
+
+// AccessMode returns "r", "w" or "rw" for the descriptor's access mode.
+func (f OpenFile) AccessMode() string {
+	if f.Flags < 0 {
+		return "?"
+	}
+	switch f.Flags & oACCMODE {
+	case oRDONLY:
+		return "r"
+	case oWRONLY:
+		return "w"
+	case oRDWR:
+		return "rw"
+	}
+	return "?"
+}
+
+// Owner is a process holding a TPU /dev node open, with every descriptor it
+// holds on that node.
+type Owner struct {
+	Pid int64
+	// StartTicks is the start time of the process, see ProcessID.
+	StartTicks uint64
+	Files      []OpenFile
+}
+
+// ID returns the pid-reuse-safe identity of the owner.
+func (o Owner) ID() ProcessID {
+	return ProcessID{Pid: o.Pid, StartTicks: o.StartTicks}
+}
+
+// readFDFlags parses the octal "flags:" line of /proc/<pid>/fdinfo/<fd>.
+func readFDFlags(pidStr, fdStr string) int {
+	b, err := os.ReadFile(filepath.Join("/proc", pidStr, "fdinfo", fdStr))
+	if err != nil {
+		return -1
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if rest, ok := strings.CutPrefix(line, "flags:"); ok {
+			flags, err := strconv.ParseInt(strings.TrimSpace(rest), 8, 64)
+			if err != nil {
+				return -1
+			}
+			return int(flags)
+		}
+	}
+	return -1
+}
+
+// GetChipProcessHolders scans /proc for processes holding a TPU device node
+// open and returns every holder per device path (e.g. /dev/vfio/0), sorted by
+// pid, with the descriptors each one holds.
+func GetChipProcessHolders() (map[string][]Owner, error) {
+	deviceOwners := make(map[string][]Owner)
+
+	procDir := "/proc"
+	pids, err := os.ReadDir(procDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read /proc directory: %w", err)
+	}
+	for _, pidEntry := range pids {
+		if !pidEntry.IsDir() {
+			continue
+		}
+
+		pidStr := pidEntry.Name()
+		pid, err := strconv.ParseInt(pidStr, 10, 64)
+		if err != nil {
+			continue // Not a PID directory, skip
+		}
+		fdDir := filepath.Join(procDir, pidStr, "fd")
+		fdEntries, err := os.ReadDir(fdDir)
+		if err != nil {
+			// Process might have terminated or we don't have permissions to read /proc/<pid>/fd.
+			if os.IsNotExist(err) || os.IsPermission(err) {
+				continue // Skip to the next PID
+			}
+			return nil, fmt.Errorf("failed to read %s: %w", fdDir, err)
+		}
+
+		// descriptors of this process, per device path
+		files := make(map[string][]OpenFile)
+		for _, fdEntry := range fdEntries {
+			fdNumStr := fdEntry.Name()
+			fd, err := strconv.Atoi(fdNumStr)
+			if err != nil {
+				continue // Not a file descriptor number, skip. Shouldn't really happen, but handle it.
+			}
+			fdLink := filepath.Join(fdDir, fdNumStr)
+			file, err := os.Readlink(fdLink)
+			if err != nil {
+				// FileNotFoundError is expected if a process closes a file descriptor
+				// while we're iterating.  Just ignore it, as well as descriptors we may
+				// not inspect.  Other errors are unexpected.
+				if os.IsNotExist(err) || os.IsPermission(err) {
+					continue
+				}
+				return nil, fmt.Errorf("readlink failed for %s: %w", fdLink, err)
+			}
+			matched := tpuDeviceRegex.MatchString(file)
+			if !matched {
+				continue
+			}
+			files[file] = append(files[file], OpenFile{FD: fd, Flags: readFDFlags(pidStr, fdNumStr)})
+		}
+		if len(files) == 0 {
+			continue
+		}
+		id, err := readProcessID(pid)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue // exited while we were scanning it
+			}
+			return nil, err
+		}
+		for file, fds := range files {
+			sort.Slice(fds, func(i, j int) bool { return fds[i].FD < fds[j].FD })
+			deviceOwners[file] = append(deviceOwners[file], Owner{Pid: pid, StartTicks: id.StartTicks, Files: fds})
+		}
+	}
+	for _, owners := range deviceOwners {
+		sort.Slice(owners, func(i, j int) bool { return owners[i].Pid < owners[j].Pid })
+	}
+	return deviceOwners, nil
+}
+
+// GetChipProcessOwners returns one owning pid per device path, the lowest pid
+// if several processes hold the device. Use GetChipProcessHolders to see all
+// of them.
+func GetChipProcessOwners() (map[string]int64, error) {
+	holders, err := GetChipProcessHolders()
+	if err != nil {
+		return nil, err
+	}
+	deviceOwners := make(map[string]int64)
+	for path, owners := range holders {
+		deviceOwners[path] = owners[0].Pid
+	}
+	return deviceOwners, nil
+}
+
+// GetChipOwners joins GetChipProcessHolders to ListDevices by /dev node and
+// returns every owner per chip Index. Chips nobody holds are absent.
+func GetChipOwners() (map[int][]Owner, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	holders, err := GetChipProcessHolders()
+	if err != nil {
+		return nil, err
+	}
+	return joinOwners(devices, holders), nil
+}
+
+// joinOwners assigns the holders of each /dev node to every chip opened
+// through it; chips sharing a vfio IOMMU group are all held by the processes
+// holding the group.
+func joinOwners(devices []Device, holders map[string][]Owner) map[int][]Owner {
+	byIndex := make(map[int][]Owner)
+	matched := make(map[string]bool)
+	for _, d := range devices {
+		if owners, ok := holders[d.DevPath]; ok && d.DevPath != "" {
+			byIndex[d.Index] = append([]Owner(nil), owners...)
+			matched[d.DevPath] = true
+		}
+	}
+	for path, owners := range holders {
+		if !matched[path] {
+			Debugf("%d processes hold %s, which is not a discovered TPU chip\n", len(owners), path)
+		}
+	}
+	return byIndex
+}
+
+// GetDeviceOwners returns one owning pid per chip Index, the lowest pid if
+// several processes hold the chip. Chips nobody holds are absent.
+func GetDeviceOwners() (map[int]int64, error) {
+	owners, err := GetChipOwners()
+	if err != nil {
+		return nil, err
+	}
+	byIndex := make(map[int]int64)
+	for index, o := range owners {
+		byIndex[index] = o[0].Pid
+	}
+	return byIndex, nil
+}
+
+// GetDevicePids returns the owning pid of every device in canonical device
+// index order, -1 for devices no process holds, and the number of busy
+// devices.
+func GetDevicePids() ([]int64, int, error) {
+	mapping, err := DeviceIndex()
+	if err != nil {
+		return nil, 0, err
+	}
+	owners, err := GetDeviceOwners()
+	if err != nil {
+		return nil, 0, err
+	}
+	pids := make([]int64, len(mapping))
+	busy := 0
+	for i, m := range mapping {
+		pid, ok := owners[m.Chip]
+		if !ok {
+			pids[i] = -1
+			continue
+		}
+		pids[i] = pid
+		busy++
+	}
+	return pids, busy, nil
+}
@@ -0,0 +1,64 @@
+package tpuinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestJoinOwnersSharedDevPath(t *testing.T) {
+	devices := []Device{
+		{Index: 0, DevPath: "/dev/vfio/3"},
+		{Index: 1, DevPath: "/dev/vfio/3"},
+		{Index: 2, DevPath: "/dev/vfio/4"},
+		{Index: 3},
+	}
+	group := []Owner{{Pid: 10}, {Pid: 11}}
+	holders := map[string][]Owner{
+		"/dev/vfio/3": group,
+		"/dev/vfio/9": {{Pid: 12}},
+		"":            {{Pid: 13}},
+	}
+	got := joinOwners(devices, holders)
+	want := map[int][]Owner{0: group, 1: group}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("joinOwners() = %v, want %v", got, want)
+	}
+}
+
+func TestReadFDFlags(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "accel0")
+	if err := os.WriteFile(path, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	pidStr := strconv.Itoa(os.Getpid())
+	for _, tt := range []struct {
+		flag int
+		want string
+	}{
+		{os.O_RDONLY, "r"},
+		{os.O_WRONLY, "w"},
+		{os.O_RDWR, "rw"},
+	} {
+		f, err := os.OpenFile(path, tt.flag, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file := OpenFile{FD: int(f.Fd()), Flags: readFDFlags(pidStr, strconv.Itoa(int(f.Fd())))}
+		f.Close()
+		if file.Flags < 0 {
+			t.Fatalf("readFDFlags(%d) = %d, want the flags of an open descriptor", file.FD, file.Flags)
+		}
+		if got := file.AccessMode(); got != tt.want {
+			t.Errorf("AccessMode() = %q for flags %o, want %q", got, file.Flags, tt.want)
+		}
+	}
+	if flags := readFDFlags(pidStr, "-1"); flags != -1 {
+		t.Errorf("readFDFlags of a missing fd = %d, want -1", flags)
+	}
+	if got := (OpenFile{Flags: -1}).AccessMode(); got != "?" {
+		t.Errorf("AccessMode() of unknown flags = %q, want ?", got)
+	}
+}
@@ -0,0 +1,303 @@
+package tpuinfo
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of the times in /proc/<pid>/stat. It is 100
+// on every Linux architecture TPU hosts run.
+const clockTicks = 100
+
+// Process describes a process holding a TPU chip, read from /proc.
+type Process struct {
+	Pid     int64
+	Cmdline []string
+	// Exe is the target of /proc/<pid>/exe, "" if it may not be read.
+	Exe  string
+	UID  int
+	User string
+	// StartTime is when the process started and Elapsed how long ago that was.
+	StartTime time.Time
+	Elapsed   time.Duration
+	// CPUPct is the CPU utilization since the previous time the process was
+	// read, or over its lifetime on the first read; 100 is one full core.
+	CPUPct   float64
+	RSSBytes int64
+	Threads  int
+	// StartTicks is the start time in clock ticks since boot, see ProcessID.
+	StartTicks uint64
+	// NSpids is the pid of the process in each nested pid namespace, from the
+	// namespace of the caller inward; the last is the pid the process sees for
+	// itself in PidNamespace. Use PidIn to translate to another namespace.
+	NSpids       []int64
+	PidNamespace PidNamespace
+	// ContainerID and ContainerRuntime ("docker", "containerd", "cri-o",
+	// "podman" or "" if unknown) are parsed from /proc/<pid>/cgroup, empty
+	// outside containers.
+	ContainerID      string
+	ContainerRuntime string
+	// PodUID and PodQOSClass are parsed from kubepods cgroups, empty outside
+	// Kubernetes pods. See also GetChipPods for the pod and container names.
+	PodUID      string
+	PodQOSClass string
+	// SlurmJobID and SlurmStepID (e.g. "0", "batch", "extern") name the Slurm
+	// job step running the process, empty outside Slurm.
+	SlurmJobID  string
+	SlurmStepID string
+	// Framework is the ML framework of the process, identified from its mapped
+	// libraries and command line, and LibTPU the libtpu it mapped, nil if none.
+	Framework Framework
+	LibTPU    *LibTPU
+
+	// libtpu is the cache key of LibTPU, see forgetLibTPUs.
+	libtpu libtpuKey
+}
+
+// ID returns the pid-reuse-safe identity of the process.
+func (p *Process) ID() ProcessID {
+	return ProcessID{Pid: p.Pid, StartTicks: p.StartTicks}
+}
+
+// Command returns the command line joined by spaces, or the executable if the
+// command line is empty (e.g. for zombies).
+func (p *Process) Command() string {
+	if len(p.Cmdline) > 0 {
+		return strings.Join(p.Cmdline, " ")
+	}
+	return p.Exe
+}
+
+type cpuSample struct {
+	cpuTicks uint64
+	at       time.Time
+}
+
+var (
+	cpu_mu      sync.Mutex
+	cpu_samples = make(map[ProcessID]cpuSample)
+)
+
+var (
+	user_mu    sync.Mutex
+	user_names = make(map[string]string)
+)
+
+// lookupUser returns the name of a uid, or the uid itself if it has none.
+// Lookups may go through NSS, e.g. to LDAP, so they are cached, failures
+// included.
+func lookupUser(uid string) string {
+	user_mu.Lock()
+	defer user_mu.Unlock()
+	if name, ok := user_names[uid]; ok {
+		return name
+	}
+	name := uid
+	if u, err := user.LookupId(uid); err == nil {
+		name = u.Username
+	}
+	user_names[uid] = name
+	return name
+}
+
+// bootTime reads btime from /proc/stat.
+func bootTime() (time.Time, error) {
+	b, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		if rest, ok := strings.CutPrefix(line, "btime "); ok {
+			secs, err := strconv.ParseInt(strings.TrimSpace(rest), 10, 64)
+			if err != nil {
+				return time.Time{}, err
+			}
+			return time.Unix(secs, 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("no btime in /proc/stat")
+}
+
+// statFields returns the fields of /proc/<pid>/stat after the command name,
+// which may itself contain spaces and parentheses. The first returned field
+// is the state, field 3 in proc(5).
+func statFields(pidStr string) ([]string, error) {
+	b, err := os.ReadFile(filepath.Join("/proc", pidStr, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	i := bytes.LastIndexByte(b, ')')
+	if i < 0 {
+		return nil, fmt.Errorf("malformed /proc/%s/stat", pidStr)
+	}
+	fields := strings.Fields(string(b[i+1:]))
+	if len(fields) < 20 {
+		return nil, fmt.Errorf("malformed /proc/%s/stat", pidStr)
+	}
+	return fields, nil
+}
+
+// statusFields returns the "Key:\tvalue" lines of /proc/<pid>/status.
+func statusFields(pidStr string) (map[string]string, error) {
+	b, err := os.ReadFile(filepath.Join("/proc", pidStr, "status"))
+	if err != nil {
+		return nil, err
+	}
+	status := make(map[string]string)
+	for _, line := range strings.Split(string(b), "\n") {
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			status[key] = strings.TrimSpace(value)
+		}
+	}
+	return status, nil
+}
+
+// readEnviron reads the initial environment of a process from
+// /proc/<pid>/environ, nil if it may not be read.
+func readEnviron(pidStr string) map[string]string {
+	b, err := os.ReadFile(filepath.Join("/proc", pidStr, "environ"))
+	if err != nil {
+		return nil
+	}
+	env := make(map[string]string)
+	for _, kv := range strings.Split(string(b), "\x00") {
+		if key, value, ok := strings.Cut(kv, "="); ok {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// ReadProcess reads the details of a process from /proc. Fields that may not
+// be read (e.g. the executable of another user's process) are left empty.
+func ReadProcess(pid int64) (*Process, error) {
+	pidStr := strconv.FormatInt(pid, 10)
+	now := time.Now()
+	stat, err := statFields(pidStr)
+	if err != nil {
+		return nil, err
+	}
+	status, err := statusFields(pidStr)
+	if err != nil {
+		return nil, err
+	}
+	p := &Process{Pid: pid, UID: -1}
+
+	if b, err := os.ReadFile(filepath.Join("/proc", pidStr, "cmdline")); err == nil {
+		for _, arg := range strings.Split(strings.TrimRight(string(b), "\x00"), "\x00") {
+			if arg != "" {
+				p.Cmdline = append(p.Cmdline, arg)
+			}
+		}
+	}
+	p.Exe, _ = os.Readlink(filepath.Join("/proc", pidStr, "exe"))
+	files := mappedFiles(pidStr)
+	p.LibTPU, p.libtpu = readLibTPU(pidStr, files)
+	p.Framework = classifyFramework(files, p.Cmdline)
+
+	// Uid: real, effective, saved, filesystem
+	if uids := strings.Fields(status["Uid"]); len(uids) > 0 {
+		if uid, err := strconv.Atoi(uids[0]); err == nil {
+			p.UID = uid
+			p.User = lookupUser(uids[0])
+		}
+	}
+	if kb, ok := strings.CutSuffix(status["VmRSS"], " kB"); ok {
+		if v, err := strconv.ParseInt(kb, 10, 64); err == nil {
+			p.RSSBytes = v * 1024
+		}
+	}
+	p.Threads, _ = strconv.Atoi(status["Threads"])
+	p.NSpids = parseNSpids(status["NSpid"])
+	p.PidNamespace, _ = PidNamespaceOf(pid)
+	cgroup := readCgroup(pidStr)
+	p.ContainerRuntime, p.ContainerID = parseContainerID(cgroup)
+	p.PodUID, p.PodQOSClass = parsePodCgroup(cgroup)
+	p.SlurmJobID, p.SlurmStepID = parseSlurmCgroup(cgroup)
+	if p.SlurmJobID == "" {
+		// e.g. Slurm without the cgroup plugins; environ is only readable for
+		// processes of the same user or by root
+		env := readEnviron(pidStr)
+		p.SlurmJobID, p.SlurmStepID = env["SLURM_JOB_ID"], env["SLURM_STEP_ID"]
+	}
+
+	// utime, stime and starttime are fields 14, 15 and 22 in proc(5)
+	utime, _ := strconv.ParseUint(stat[11], 10, 64)
+	stime, _ := strconv.ParseUint(stat[12], 10, 64)
+	p.StartTicks, _ = strconv.ParseUint(stat[19], 10, 64)
+	if boot, err := bootTime(); err == nil {
+		p.StartTime = boot.Add(time.Duration(p.StartTicks) * time.Second / clockTicks)
+		p.Elapsed = now.Sub(p.StartTime)
+	}
+	p.CPUPct = sampleCPU(p, utime+stime, now)
+	return p, nil
+}
+
+// sampleCPU returns the CPU utilization of p since its previous sample, or
+// over its lifetime if there is none, and records the new sample.
+func sampleCPU(p *Process, cpuTicks uint64, now time.Time) float64 {
+	cpu_mu.Lock()
+	defer cpu_mu.Unlock()
+	prev, ok := cpu_samples[p.ID()]
+	cpu_samples[p.ID()] = cpuSample{cpuTicks: cpuTicks, at: now}
+
+	if ok && now.After(prev.at) && cpuTicks >= prev.cpuTicks {
+		busy := float64(cpuTicks-prev.cpuTicks) / clockTicks
+		return 100 * busy / now.Sub(prev.at).Seconds()
+	}
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return 100 * float64(cpuTicks) / clockTicks / p.Elapsed.Seconds()
+}
+
+// forgetCPUSamples drops the CPU samples of processes not in keep.
+func forgetCPUSamples(keep map[int64]*Process) {
+	cpu_mu.Lock()
+	defer cpu_mu.Unlock()
+	for id := range cpu_samples {
+		if p, ok := keep[id.Pid]; !ok || p.ID() != id {
+			delete(cpu_samples, id)
+		}
+	}
+}
+
+// GetChipProcesses returns the details of every process holding a TPU chip,
+// per chip Index, in pid order. A process holding several chips is read once
+// and shared between them. CPU utilization is measured between calls.
+func GetChipProcesses() (map[int][]*Process, error) {
+	owners, err := GetChipOwners()
+	if err != nil {
+		return nil, err
+	}
+	procs := make(map[int64]*Process)
+	byIndex := make(map[int][]*Process)
+	for index, chip_owners := range owners {
+		for _, o := range chip_owners {
+			p, ok := procs[o.Pid]
+			if !ok {
+				if p, err = ReadProcess(o.Pid); err != nil {
+					// the process exited since the scan
+					Debugf("Could not read process %d: %v\n", o.Pid, err)
+					continue
+				}
+				if p.ID() != o.ID() {
+					Debugf("Process %v exited and its pid was reused\n", o.ID())
+					continue
+				}
+				procs[o.Pid] = p
+			}
+			byIndex[index] = append(byIndex[index], p)
+		}
+	}
+	forgetCPUSamples(procs)
+	forgetLibTPUs(procs)
+	return byIndex, nil
+}
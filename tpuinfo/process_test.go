@@ -0,0 +1,78 @@
+package tpuinfo
+
+import (
+	"os"
+	"os/user"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReadProcessSelf(t *testing.T) {
+	before := time.Now()
+	p, err := ReadProcess(int64(os.Getpid()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	id, err := readProcessID(p.Pid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID() != id {
+		t.Errorf("ID() = %v, want %v", p.ID(), id)
+	}
+	if p.UID != os.Getuid() {
+		t.Errorf("UID = %d, want %d", p.UID, os.Getuid())
+	}
+	if u, err := user.LookupId(strconv.Itoa(os.Getuid())); err == nil && p.User != u.Username {
+		t.Errorf("User = %q, want %q", p.User, u.Username)
+	}
+	if len(p.Cmdline) == 0 || p.Cmdline[0] != os.Args[0] {
+		t.Errorf("Cmdline = %q, want to start with %q", p.Cmdline, os.Args[0])
+	}
+	if p.RSSBytes <= 0 {
+		t.Errorf("RSSBytes = %d, want > 0", p.RSSBytes)
+	}
+	if p.Threads < 1 {
+		t.Errorf("Threads = %d, want >= 1", p.Threads)
+	}
+	// the start time is rounded to ticks and boot time to seconds
+	if p.StartTime.IsZero() || p.StartTime.After(before.Add(time.Second)) || p.Elapsed < -time.Second {
+		t.Errorf("StartTime = %v, Elapsed = %v, want a start before %v", p.StartTime, p.Elapsed, before)
+	}
+	if p.CPUPct < 0 {
+		t.Errorf("CPUPct = %v, want >= 0", p.CPUPct)
+	}
+}
+
+func TestSampleCPU(t *testing.T) {
+	p := &Process{Pid: -1, StartTicks: 1, Elapsed: 10 * time.Second}
+	t.Cleanup(func() { forgetCPUSamples(nil) })
+	now := time.Now()
+	// first sample: 5s of CPU over a 10s lifetime
+	if got := sampleCPU(p, 5*clockTicks, now); got != 50 {
+		t.Errorf("first sample = %v%%, want 50%%", got)
+	}
+	// then 2s of CPU over the next second
+	if got := sampleCPU(p, 7*clockTicks, now.Add(time.Second)); got != 200 {
+		t.Errorf("second sample = %v%%, want 200%%", got)
+	}
+	// a recycled pid starts over from its lifetime
+	reused := &Process{Pid: -1, StartTicks: 2, Elapsed: 4 * time.Second}
+	if got := sampleCPU(reused, clockTicks, now.Add(2*time.Second)); got != 25 {
+		t.Errorf("sample of a reused pid = %v%%, want 25%%", got)
+	}
+}
+
+func TestLookupUserCachesFailures(t *testing.T) {
+	const uid = "4294967294"
+	if got := lookupUser(uid); got != uid {
+		t.Skipf("uid %s has a name, %q", uid, got)
+	}
+	user_mu.Lock()
+	_, cached := user_names[uid]
+	user_mu.Unlock()
+	if !cached {
+		t.Errorf("lookupUser(%s) was not cached", uid)
+	}
+}
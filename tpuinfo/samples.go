@@ -0,0 +1,267 @@
+package tpuinfo
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+)
+
+// ValueKind is the type of a sample value as reported by the runtime.
+type ValueKind int
+
+const (
+	KindUnknown ValueKind = iota
+	KindDouble
+	KindInt
+	KindString
+	KindBool
+	KindUint
+)
+
+func (k ValueKind) String() string {
+	switch k {
+	case KindDouble:
+		return "double"
+	case KindInt:
+		return "int"
+	case KindString:
+		return "string"
+	case KindBool:
+		return "bool"
+	case KindUint:
+		return "uint"
+	}
+	return "unknown"
+}
+
+// MeasureType is the measure oneof of a runtime Metric.
+type MeasureType int
+
+const (
+	MeasureUnknown MeasureType = iota
+	MeasureGauge
+	MeasureCounter
+	MeasureDistribution
+	MeasureSummary
+)
+
+func (m MeasureType) String() string {
+	switch m {
+	case MeasureGauge:
+		return "gauge"
+	case MeasureCounter:
+		return "counter"
+	case MeasureDistribution:
+		return "distribution"
+	case MeasureSummary:
+		return "summary"
+	}
+	return "unknown"
+}
+
+func measureType(m *pb.Metric) MeasureType {
+	switch m.GetMeasure().(type) {
+	case *pb.Metric_Gauge:
+		return MeasureGauge
+	case *pb.Metric_Counter:
+		return MeasureCounter
+	case *pb.Metric_Distribution:
+		return MeasureDistribution
+	case *pb.Metric_Summary:
+		return MeasureSummary
+	}
+	return MeasureUnknown
+}
+
+// Value is a sample value with its original type preserved. Only the field
+// matching Kind is set.
+type Value struct {
+	Kind   ValueKind
+	Double float64
+	Int    int64
+	Str    string
+	Bool   bool
+	Uint   uint64
+}
+
+// Float returns the value converted to float64 (bools as 0/1, strings parsed
+// if possible).
+func (v Value) Float() float64 {
+	switch v.Kind {
+	case KindDouble:
+		return v.Double
+	case KindInt:
+		return float64(v.Int)
+	case KindUint:
+		return float64(v.Uint)
+	case KindBool:
+		if v.Bool {
+			return 1
+		}
+	case KindString:
+		if f, err := strconv.ParseFloat(v.Str, 64); err == nil {
+			return f
+		}
+	}
+	return 0
+}
+
+// Int64 returns the value converted to int64 without going through float64
+// for integer kinds.
+func (v Value) Int64() int64 {
+	switch v.Kind {
+	case KindInt:
+		return v.Int
+	case KindUint:
+		return int64(v.Uint)
+	}
+	return int64(v.Float())
+}
+
+func (v Value) String() string {
+	switch v.Kind {
+	case KindDouble:
+		return strconv.FormatFloat(v.Double, 'g', -1, 64)
+	case KindInt:
+		return strconv.FormatInt(v.Int, 10)
+	case KindUint:
+		return strconv.FormatUint(v.Uint, 10)
+	case KindString:
+		return v.Str
+	case KindBool:
+		return strconv.FormatBool(v.Bool)
+	}
+	return ""
+}
+
+func gaugeValue(g *pb.Gauge) Value {
+	switch v := g.GetValue().(type) {
+	case *pb.Gauge_AsDouble:
+		return Value{Kind: KindDouble, Double: v.AsDouble}
+	case *pb.Gauge_AsInt:
+		return Value{Kind: KindInt, Int: v.AsInt}
+	case *pb.Gauge_AsString:
+		return Value{Kind: KindString, Str: v.AsString}
+	case *pb.Gauge_AsBool:
+		return Value{Kind: KindBool, Bool: v.AsBool}
+	}
+	return Value{}
+}
+
+// Sample is one labelled datapoint of a runtime metric. For distributions,
+// Value holds the mean and Histogram the buckets; for summaries, Value holds
+// the mean and Summary the quantiles.
+type Sample struct {
+	Labels    Labels
+	Timestamp time.Time
+	Measure   MeasureType
+	Value     Value
+	Histogram *Histogram
+	Summary   *Summary
+	Exemplar  *Exemplar
+
+	deviceID    int64
+	hasDeviceID bool
+}
+
+// DeviceID returns the chip/core id of the sample: the value of a device id
+// label such as "device-id", or its only label if that is an integer.
+func (s Sample) DeviceID() (int64, bool) {
+	return s.deviceID, s.hasDeviceID
+}
+
+// LabelString formats the labels as "key=value,key2=value2".
+func (s Sample) LabelString() string {
+	return s.Labels.String()
+}
+
+// RuntimeMetric is a decoded TPUMetric. Samples are sorted by label set, so
+// samples of the same series are adjacent.
+type RuntimeMetric struct {
+	Name        string
+	Description string
+	Samples     []Sample
+}
+
+// Series is the samples of a RuntimeMetric sharing one label set.
+type Series struct {
+	Labels  Labels
+	Samples []Sample
+}
+
+// Series groups the samples by label set, in label set order.
+func (m *RuntimeMetric) Series() []Series {
+	var series []Series
+	for _, s := range m.Samples {
+		if n := len(series); n > 0 && series[n-1].Labels.Compare(s.Labels) == 0 {
+			series[n-1].Samples = append(series[n-1].Samples, s)
+			continue
+		}
+		series = append(series, Series{Labels: s.Labels, Samples: []Sample{s}})
+	}
+	return series
+}
+
+func decodeSample(m *pb.Metric) Sample {
+	s := Sample{Measure: measureType(m)}
+	switch s.Measure {
+	case MeasureGauge:
+		s.Value = gaugeValue(m.GetGauge())
+	case MeasureCounter:
+		s.Value = counterValue(m.GetCounter())
+		s.Exemplar = newExemplar(m.GetCounter().GetExemplar())
+	case MeasureSummary:
+		s.Summary = newSummary(m.GetSummary())
+		s.Value = Value{Kind: KindDouble, Double: s.Summary.Mean()}
+	case MeasureDistribution:
+		s.Value = Value{Kind: KindDouble, Double: m.GetDistribution().GetMean()}
+		h, err := newHistogram(m.GetDistribution())
+		if err != nil {
+			Debugf("Could not decode distribution: %v\n", err)
+		}
+		s.Histogram = h
+	}
+	if ts := m.GetTimestamp(); ts != nil {
+		s.Timestamp = ts.AsTime()
+	}
+	s.Labels = decodeAttributes(m.GetAttribute())
+	s.deviceID, s.hasDeviceID = s.Labels.deviceID()
+	return s
+}
+
+func decodeRuntimeMetric(r *pb.MetricResponse) *RuntimeMetric {
+	tm := r.GetMetric()
+	rm := &RuntimeMetric{Name: tm.GetName(), Description: tm.GetDescription()}
+	for _, m := range tm.GetMetrics() {
+		rm.Samples = append(rm.Samples, decodeSample(m))
+	}
+	sort.SliceStable(rm.Samples, func(i, j int) bool {
+		return rm.Samples[i].Labels.Compare(rm.Samples[j].Labels) < 0
+	})
+	return rm
+}
+
+// MetricByName fetches any runtime metric by name and returns its samples
+// with their value types, timestamps and attributes.
+func (c *Client) MetricByName(ctx context.Context, name string) (*RuntimeMetric, error) {
+	ctx, cancel := c.withTimeout(ctx)
+	defer cancel()
+	r, err := c.getRuntimeMetric(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return decodeRuntimeMetric(r), nil
+}
+
+// GetMetricByName fetches a runtime metric by name from the default local
+// metrics server.
+func GetMetricByName(ctx context.Context, name string) (*RuntimeMetric, error) {
+	c, err := SharedClient()
+	if err != nil {
+		return nil, err
+	}
+	return c.MetricByName(ctx, name)
+}
@@ -0,0 +1,97 @@
+package tpuinfo
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	pb "github.com/rdyro/libtpuinfo/tpu_info_proto"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// staticMetricServer serves fixed responses by metric name.
+type staticMetricServer struct {
+	pb.UnimplementedRuntimeMetricServiceServer
+	metrics map[string]*pb.TPUMetric
+}
+
+func (s *staticMetricServer) GetRuntimeMetric(ctx context.Context, req *pb.MetricRequest) (*pb.MetricResponse, error) {
+	m, ok := s.metrics[req.GetMetricName()]
+	if !ok {
+		return nil, status.Errorf(codes.NotFound, "no metric %s", req.GetMetricName())
+	}
+	return &pb.MetricResponse{Metric: m}, nil
+}
+
+func gaugeMetric(device int64, ts time.Time, g *pb.Gauge) *pb.Metric {
+	return &pb.Metric{
+		Attribute: &pb.Attribute{Key: "device-id", Value: &pb.AttrValue{Attr: &pb.AttrValue_IntAttr{IntAttr: device}}},
+		Timestamp: timestamppb.New(ts),
+		Measure:   &pb.Metric_Gauge{Gauge: g},
+	}
+}
+
+func TestMetricByNameKeepsValueKinds(t *testing.T) {
+	ts := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	srv := &staticMetricServer{metrics: map[string]*pb.TPUMetric{
+		"double": {Name: "double", Metrics: []*pb.Metric{gaugeMetric(1, ts, &pb.Gauge{Value: &pb.Gauge_AsDouble{AsDouble: 1.5}})}},
+		"int":    {Name: "int", Metrics: []*pb.Metric{gaugeMetric(1, ts, &pb.Gauge{Value: &pb.Gauge_AsInt{AsInt: 1<<62 + 1}})}},
+		"string": {Name: "string", Metrics: []*pb.Metric{gaugeMetric(1, ts, &pb.Gauge{Value: &pb.Gauge_AsString{AsString: "v5e"}})}},
+		"bool":   {Name: "bool", Metrics: []*pb.Metric{gaugeMetric(1, ts, &pb.Gauge{Value: &pb.Gauge_AsBool{AsBool: true}})}},
+	}}
+	c, err := NewClient(WithTarget(startFakeMetricServer(t, srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	tests := []struct {
+		name string
+		want Value
+	}{
+		{"double", Value{Kind: KindDouble, Double: 1.5}},
+		{"int", Value{Kind: KindInt, Int: 1<<62 + 1}},
+		{"string", Value{Kind: KindString, Str: "v5e"}},
+		{"bool", Value{Kind: KindBool, Bool: true}},
+	}
+	for _, tt := range tests {
+		m, err := c.MetricByName(context.Background(), tt.name)
+		if err != nil {
+			t.Fatalf("MetricByName(%q): %v", tt.name, err)
+		}
+		if m.Name != tt.name || len(m.Samples) != 1 {
+			t.Fatalf("MetricByName(%q) = %+v, want one sample", tt.name, m)
+		}
+		s := m.Samples[0]
+		if s.Measure != MeasureGauge || s.Value != tt.want {
+			t.Errorf("MetricByName(%q) = %v %+v, want gauge %+v", tt.name, s.Measure, s.Value, tt.want)
+		}
+		if !s.Timestamp.Equal(ts) {
+			t.Errorf("MetricByName(%q) timestamp = %v, want %v", tt.name, s.Timestamp, ts)
+		}
+		if got := s.LabelString(); got != "device-id=1" {
+			t.Errorf("MetricByName(%q) labels = %q, want device-id=1", tt.name, got)
+		}
+		if id, ok := s.DeviceID(); !ok || id != 1 {
+			t.Errorf("MetricByName(%q) DeviceID() = %d, %v, want 1, true", tt.name, id, ok)
+		}
+	}
+}
+
+func TestMetricByNameUnknown(t *testing.T) {
+	srv := &staticMetricServer{}
+	c, err := NewClient(WithTarget(startFakeMetricServer(t, srv)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	_, err = c.MetricByName(context.Background(), "missing")
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("MetricByName(missing) = %v, want NotFound", err)
+	}
+	if me, ok := err.(*MetricsError); !ok || me.Metric != "missing" {
+		t.Errorf("MetricByName(missing) = %#v, want a *MetricsError for the metric", err)
+	}
+}
@@ -0,0 +1,202 @@
+package tpuinfo
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	slurmJobRegex  = regexp.MustCompile(`^job_(\d+)$`)
+	slurmStepRegex = regexp.MustCompile(`^step_(\w+)$`)
+)
+
+// parseSlurmCgroup finds the Slurm job and step in the contents of
+// /proc/<pid>/cgroup, as created by the cgroup v1 plugin
+// (/slurm/uid_X/job_Y/step_Z) and by slurmstepd on cgroup v2
+// (/system.slice/slurmstepd.scope/job_Y/step_Z).
+func parseSlurmCgroup(cgroup string) (job, step string) {
+	for _, line := range strings.Split(cgroup, "\n") {
+		parts := strings.SplitN(line, ":", 3)
+		if len(parts) != 3 || !strings.Contains(parts[2], "slurm") {
+			continue
+		}
+		for _, dir := range strings.Split(strings.Trim(parts[2], "/"), "/") {
+			if m := slurmJobRegex.FindStringSubmatch(dir); m != nil {
+				job = m[1]
+			} else if m := slurmStepRegex.FindStringSubmatch(dir); m != nil && job != "" {
+				step = m[1]
+			}
+		}
+		if job != "" {
+			return job, step
+		}
+	}
+	return "", ""
+}
+
+// slurmLabels returns the Slurm job step of the process as slurm_job_id and
+// slurm_step_id labels, each only when known.
+func (p *Process) slurmLabels() Labels {
+	return appendKnown(nil,
+		Label{"slurm_job_id", p.SlurmJobID},
+		Label{"slurm_step_id", p.SlurmStepID},
+	)
+}
+
+// cpuDevicesDir holds the topology of every online CPU, a variable so that
+// tests can point it at a fake tree.
+var cpuDevicesDir = "/sys/devices/system/cpu"
+
+// parseCPUList parses a kernel cpulist such as "0-3,8-11".
+func parseCPUList(list string) ([]int, error) {
+	var cpus []int
+	for _, r := range strings.Split(strings.TrimSpace(list), ",") {
+		if r == "" {
+			continue
+		}
+		lo, hi, isRange := strings.Cut(r, "-")
+		first, err := strconv.Atoi(lo)
+		if err != nil {
+			return nil, fmt.Errorf("malformed cpulist %q", list)
+		}
+		last := first
+		if isRange {
+			if last, err = strconv.Atoi(hi); err != nil || last < first {
+				return nil, fmt.Errorf("malformed cpulist %q", list)
+			}
+		}
+		for cpu := first; cpu <= last; cpu++ {
+			cpus = append(cpus, cpu)
+		}
+	}
+	return cpus, nil
+}
+
+// formatCPUList formats sorted, unique ids as a cpulist, e.g. "0-3,8".
+func formatCPUList(ids []int) string {
+	var parts []string
+	for i := 0; i < len(ids); {
+		j := i
+		for j+1 < len(ids) && ids[j+1] == ids[j]+1 {
+			j++
+		}
+		if j > i {
+			parts = append(parts, fmt.Sprintf("%d-%d", ids[i], ids[j]))
+		} else {
+			parts = append(parts, strconv.Itoa(ids[i]))
+		}
+		i = j + 1
+	}
+	return strings.Join(parts, ",")
+}
+
+// slurmCoreIndex maps OS CPU ids to the Slurm abstract core index gres.conf
+// Cores= expects: physical cores numbered by socket, then core id, with the
+// hyperthreads of a core sharing its index. CPUs without a topology, e.g.
+// offline ones, are left out.
+func slurmCoreIndex() (map[int]int, error) {
+	entries, err := os.ReadDir(cpuDevicesDir)
+	if err != nil {
+		return nil, err
+	}
+	type core struct{ pkg, id int }
+	cpuCore := make(map[int]core)
+	for _, e := range entries {
+		cpu, err := strconv.Atoi(strings.TrimPrefix(e.Name(), "cpu"))
+		if err != nil || !strings.HasPrefix(e.Name(), "cpu") {
+			continue
+		}
+		topology := filepath.Join(cpuDevicesDir, e.Name(), "topology")
+		c := core{readSysfsInt(filepath.Join(topology, "physical_package_id"), -1), readSysfsInt(filepath.Join(topology, "core_id"), -1)}
+		if c.pkg < 0 || c.id < 0 {
+			Debugf("No topology for %s, leaving it out of the core index\n", e.Name())
+			continue
+		}
+		cpuCore[cpu] = c
+	}
+	if len(cpuCore) == 0 {
+		return nil, fmt.Errorf("no CPU topology in %s", cpuDevicesDir)
+	}
+	cores := make([]core, 0, len(cpuCore))
+	seen := make(map[core]bool)
+	for _, c := range cpuCore {
+		if !seen[c] {
+			seen[c] = true
+			cores = append(cores, c)
+		}
+	}
+	sort.Slice(cores, func(i, j int) bool {
+		if cores[i].pkg != cores[j].pkg {
+			return cores[i].pkg < cores[j].pkg
+		}
+		return cores[i].id < cores[j].id
+	})
+	index := make(map[core]int, len(cores))
+	for i, c := range cores {
+		index[c] = i
+	}
+	cpuIndex := make(map[int]int, len(cpuCore))
+	for cpu, c := range cpuCore {
+		cpuIndex[cpu] = index[c]
+	}
+	return cpuIndex, nil
+}
+
+// slurmCores converts a device's local cpulist to Slurm core indexes. CPUs
+// missing from the core index are skipped; "" is returned if none is left or
+// the CPU topology could not be read, since OS CPU ids are not core indexes.
+func slurmCores(cpuIndex map[int]int, cpulist string) string {
+	cpus, err := parseCPUList(cpulist)
+	if err != nil || cpuIndex == nil {
+		return ""
+	}
+	seen := make(map[int]bool)
+	var cores []int
+	for _, cpu := range cpus {
+		i, ok := cpuIndex[cpu]
+		if !ok {
+			continue
+		}
+		if !seen[i] {
+			seen[i] = true
+			cores = append(cores, i)
+		}
+	}
+	sort.Ints(cores)
+	return formatCPUList(cores)
+}
+
+// GresConf returns a Slurm gres.conf fragment declaring every chip as a "tpu"
+// GRES with the chip type as Type, its /dev node as File and the cores local
+// to it as Cores, left out if the CPU topology is unknown. Chips without a /dev node are left out with a comment, and
+// chips sharing a /dev node (e.g. one vfio group) are declared once.
+func GresConf(devices []Device) string {
+	cpuIndex, err := slurmCoreIndex()
+	if err != nil {
+		Debugf("Could not read the CPU topology, leaving out Cores=: %v\n", err)
+	}
+	var b strings.Builder
+	declared := make(map[string]bool)
+	for _, d := range devices {
+		if d.DevPath == "" {
+			fmt.Fprintf(&b, "# %s (%s): no /dev node, driver %q\n", d.BDF, d.Chip, d.Driver)
+			continue
+		}
+		if declared[d.DevPath] {
+			Debugf("Chip %s shares %s with another chip\n", d.BDF, d.DevPath)
+			continue
+		}
+		declared[d.DevPath] = true
+		fmt.Fprintf(&b, "Name=tpu Type=%s File=%s", d.Chip, d.DevPath)
+		if cores := slurmCores(cpuIndex, d.LocalCPUList); cores != "" {
+			fmt.Fprintf(&b, " Cores=%s", cores)
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
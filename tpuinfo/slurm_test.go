@@ -0,0 +1,153 @@
+package tpuinfo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+func TestParseSlurmCgroup(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		cgroup    string
+		job, step string
+	}{
+		{"v1", "12:pids:/user.slice\n4:memory:/slurm/uid_1000/job_42/step_0\n", "42", "0"},
+		{"v1 batch", "4:cpuset:/slurm/uid_1000/job_42/step_batch/task_0\n", "42", "batch"},
+		{"v2", "0::/system.slice/slurmstepd.scope/job_7/step_extern/user/task_0\n", "7", "extern"},
+		{"v2 job only", "0::/system.slice/slurmstepd.scope/job_7\n", "7", ""},
+		{"not slurm", "0::/user.slice/job_7/step_0\n", "", ""},
+		{"host", "0::/init.scope\n", "", ""},
+	} {
+		job, step := parseSlurmCgroup(tt.cgroup)
+		if job != tt.job || step != tt.step {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tt.name, job, step, tt.job, tt.step)
+		}
+	}
+}
+
+func TestCPUList(t *testing.T) {
+	for _, tt := range []struct {
+		list   string
+		cpus   []int
+		format string
+	}{
+		{"0-3,8-11\n", []int{0, 1, 2, 3, 8, 9, 10, 11}, "0-3,8-11"},
+		{"5", []int{5}, "5"},
+		{"0,2,4-5", []int{0, 2, 4, 5}, "0,2,4-5"},
+		{"", nil, ""},
+	} {
+		cpus, err := parseCPUList(tt.list)
+		if err != nil || !reflect.DeepEqual(cpus, tt.cpus) {
+			t.Errorf("parseCPUList(%q) = %v, %v; want %v", tt.list, cpus, err, tt.cpus)
+			continue
+		}
+		if got := formatCPUList(cpus); got != tt.format {
+			t.Errorf("formatCPUList(%v) = %q, want %q", cpus, got, tt.format)
+		}
+	}
+	for _, list := range []string{"a", "3-1", "1-x"} {
+		if cpus, err := parseCPUList(list); err == nil {
+			t.Errorf("parseCPUList(%q) = %v, want an error", list, cpus)
+		}
+	}
+}
+
+func TestSlurmCores(t *testing.T) {
+	// two sockets of two cores, with hyperthreads 4-7 on the same cores
+	cpuIndex := map[int]int{0: 0, 1: 1, 2: 2, 3: 3, 4: 0, 5: 1, 6: 2, 7: 3}
+	for _, tt := range []struct{ cpulist, want string }{
+		{"0-1,4-5", "0-1"},
+		{"2-3,6-7", "2-3"},
+		{"0,8", "0"}, // unknown CPUs are skipped
+		{"8", ""},
+	} {
+		if got := slurmCores(cpuIndex, tt.cpulist); got != tt.want {
+			t.Errorf("slurmCores(%q) = %q, want %q", tt.cpulist, got, tt.want)
+		}
+	}
+	if got := slurmCores(nil, "0-3"); got != "" {
+		t.Errorf("slurmCores without topology = %q, want none", got)
+	}
+}
+
+// useFakeCPUTopology points slurmCoreIndex at a fake CPU tree for the rest of
+// the test; topology maps a CPU to its package and core id, nil for a CPU
+// without a topology directory.
+func useFakeCPUTopology(t *testing.T, topology map[int]*[2]int) {
+	t.Helper()
+	root := t.TempDir()
+	for cpu, c := range topology {
+		dir := filepath.Join(root, "cpu"+strconv.Itoa(cpu))
+		if c != nil {
+			dir = filepath.Join(dir, "topology")
+		}
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if c == nil {
+			continue
+		}
+		for name, v := range map[string]int{"physical_package_id": c[0], "core_id": c[1]} {
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(strconv.Itoa(v)+"\n"), 0o644); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	saved := cpuDevicesDir
+	cpuDevicesDir = root
+	t.Cleanup(func() { cpuDevicesDir = saved })
+}
+
+func TestSlurmCoreIndex(t *testing.T) {
+	// one socket with core ids 0 and 4, hyperthreads 2-3, and offline CPU 5
+	useFakeCPUTopology(t, map[int]*[2]int{0: {0, 0}, 1: {0, 4}, 2: {0, 0}, 3: {0, 4}, 5: nil})
+	got, err := slurmCoreIndex()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[int]int{0: 0, 1: 1, 2: 0, 3: 1}; !reflect.DeepEqual(got, want) {
+		t.Errorf("slurmCoreIndex() = %v, want %v", got, want)
+	}
+
+	useFakeCPUTopology(t, map[int]*[2]int{0: nil})
+	if got, err := slurmCoreIndex(); err == nil {
+		t.Errorf("slurmCoreIndex() without any topology = %v, want an error", got)
+	}
+}
+
+func TestGresConf(t *testing.T) {
+	devices := []Device{
+		{Index: 0, BDF: "0000:00:04.0", Chip: &V5E, DevPath: "/dev/vfio/0"},
+		{Index: 1, BDF: "0000:00:05.0", Chip: &V5E, DevPath: "/dev/vfio/0"},
+		{Index: 2, BDF: "0000:00:06.0", Chip: &V5E, DevPath: "/dev/vfio/1"},
+		{Index: 3, BDF: "0000:00:07.0", Chip: &V5E, Driver: "none"},
+	}
+	want := "Name=tpu Type=v5e File=/dev/vfio/0\n" +
+		"Name=tpu Type=v5e File=/dev/vfio/1\n" +
+		"# 0000:00:07.0 (v5e): no /dev node, driver \"none\"\n"
+	if got := GresConf(devices); got != want {
+		t.Errorf("GresConf() =\n%s\nwant\n%s", got, want)
+	}
+
+	// OS CPU ids are never written as core indexes
+	local := []Device{{Index: 0, BDF: "0000:00:04.0", Chip: &V5E, DevPath: "/dev/vfio/0", LocalCPUList: "0-3"}}
+	useFakeCPUTopology(t, map[int]*[2]int{0: {0, 0}, 1: {0, 4}, 2: {0, 0}, 3: {0, 4}})
+	if got, want := GresConf(local), "Name=tpu Type=v5e File=/dev/vfio/0 Cores=0-1\n"; got != want {
+		t.Errorf("GresConf() = %q, want %q", got, want)
+	}
+	useFakeCPUTopology(t, nil)
+	if got, want := GresConf(local), "Name=tpu Type=v5e File=/dev/vfio/0\n"; got != want {
+		t.Errorf("GresConf() without a CPU topology = %q, want %q", got, want)
+	}
+}
+
+func TestProcessLabels(t *testing.T) {
+	p := &Process{ContainerID: "abc", PodQOSClass: "burstable", SlurmJobID: "42"}
+	want := Labels{{"container_id", "abc"}, {"qos_class", "burstable"}, {"slurm_job_id", "42"}}
+	if got := p.Labels(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Labels() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,183 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownTopology is returned when the chips of a host do not fill any
+// known host shape of their chip type.
+var ErrUnknownTopology = errors.New("unknown host topology")
+
+// Coords are the position of a chip in the host bounds.
+type Coords struct {
+	X, Y, Z int
+}
+
+func (c Coords) String() string {
+	return fmt.Sprintf("(%d,%d,%d)", c.X, c.Y, c.Z)
+}
+
+// TopologyChip is one chip placed in the host topology.
+type TopologyChip struct {
+	Device Device
+	Coords Coords
+	// Neighbors are the Device.Index of the chips one ICI hop away within the
+	// host, ascending.
+	Neighbors []int
+}
+
+// Topology is the host-local chip topology: the chips of one type laid out
+// in their host bounds.
+type Topology struct {
+	Chip *TpuChip
+	// Bounds is the chip grid of this host, x by y by z.
+	Bounds [3]int
+	// Chips are in PCI order, i.e. by Device.Index.
+	Chips []TopologyChip
+}
+
+// hostBounds returns the bounds a host with count chips of a type fills: the
+// full HostBounds, or for smaller hosts (e.g. the 1 and 4 chip v5e and v6e
+// hosts) the bounds halved along y, then x, then z.
+func hostBounds(chip *TpuChip, count int) ([3]int, bool) {
+	bounds := chip.Value.HostBounds
+	for {
+		n := bounds[0] * bounds[1] * bounds[2]
+		if n == count {
+			return bounds, true
+		}
+		if n < count || n == 1 {
+			return [3]int{}, false
+		}
+		switch {
+		case bounds[1] > 1:
+			bounds[1] /= 2
+		case bounds[0] > 1:
+			bounds[0] /= 2
+		default:
+			bounds[2] /= 2
+		}
+	}
+}
+
+// NewTopology lays out discovered chips in their host bounds. Chips are
+// placed in PCI order with x varying fastest, the order libtpu enumerates the
+// chips of a host in, and chips whose coordinates differ by one along a
+// single axis are ICI neighbours. Hosts with several chip types return
+// ErrMixedChipTypes.
+func NewTopology(devices []Device) (*Topology, error) {
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("%w: no TPU chips", ErrUnknownTopology)
+	}
+	chip := devices[0].Chip
+	for _, d := range devices[1:] {
+		if d.Chip != chip {
+			return nil, ErrMixedChipTypes
+		}
+	}
+	bounds, ok := hostBounds(chip, len(devices))
+	if !ok {
+		return nil, fmt.Errorf("%w: %d %s chips", ErrUnknownTopology, len(devices), chip)
+	}
+	t := &Topology{Chip: chip, Bounds: bounds, Chips: make([]TopologyChip, len(devices))}
+	byCoords := make(map[Coords]int)
+	for i, d := range devices {
+		c := Coords{X: i % bounds[0], Y: i / bounds[0] % bounds[1], Z: i / (bounds[0] * bounds[1])}
+		t.Chips[i] = TopologyChip{Device: d, Coords: c}
+		byCoords[c] = i
+	}
+	for i := range t.Chips {
+		c := t.Chips[i].Coords
+		for _, n := range []Coords{
+			{c.X - 1, c.Y, c.Z}, {c.X + 1, c.Y, c.Z},
+			{c.X, c.Y - 1, c.Z}, {c.X, c.Y + 1, c.Z},
+			{c.X, c.Y, c.Z - 1}, {c.X, c.Y, c.Z + 1},
+		} {
+			if j, ok := byCoords[n]; ok {
+				t.Chips[i].Neighbors = append(t.Chips[i].Neighbors, t.Chips[j].Device.Index)
+			}
+		}
+		sort.Ints(t.Chips[i].Neighbors)
+	}
+	return t, nil
+}
+
+// GetTopology returns the topology of the TPU chips of this host.
+func GetTopology() (*Topology, error) {
+	devices, err := ListDevices()
+	if err != nil {
+		return nil, err
+	}
+	return NewTopology(devices)
+}
+
+// At returns the chip at the given coordinates.
+func (t *Topology) At(c Coords) (TopologyChip, bool) {
+	for _, chip := range t.Chips {
+		if chip.Coords == c {
+			return chip, true
+		}
+	}
+	return TopologyChip{}, false
+}
+
+// Diagram draws the chips of each z plane as a grid, x to the right and y
+// downwards, with ICI links between neighbours:
+//
+//	[0 accel0]--[1 accel1]
+//	     |           |
+//	[2 accel2]--[3 accel3]
+func (t *Topology) Diagram() string {
+	labels := make([]string, len(t.Chips))
+	width := 0
+	for i, chip := range t.Chips {
+		name := chip.Device.DevPath
+		if k := strings.LastIndex(name, "/dev/"); k >= 0 {
+			name = name[k+len("/dev/"):]
+		}
+		labels[i] = fmt.Sprintf("[%d %s]", chip.Device.Index, name)
+		if name == "" {
+			labels[i] = fmt.Sprintf("[%d]", chip.Device.Index)
+		}
+		width = max(width, len(labels[i]))
+	}
+	const link = "--"
+	var b strings.Builder
+	for z := 0; z < t.Bounds[2]; z++ {
+		if t.Bounds[2] > 1 {
+			fmt.Fprintf(&b, "z=%d\n", z)
+		}
+		for y := 0; y < t.Bounds[1]; y++ {
+			if y > 0 {
+				// vertical links, centred under each chip
+				for x := 0; x < t.Bounds[0]; x++ {
+					if x > 0 {
+						b.WriteString(strings.Repeat(" ", len(link)))
+					}
+					cell := strings.Repeat(" ", width/2) + "|"
+					b.WriteString(cell + strings.Repeat(" ", width-len(cell)))
+				}
+				b.WriteString("\n")
+			}
+			for x := 0; x < t.Bounds[0]; x++ {
+				i := (z*t.Bounds[1]+y)*t.Bounds[0] + x
+				if x+1 < t.Bounds[0] {
+					// the link to the right neighbour fills the padding
+					b.WriteString(labels[i] + strings.Repeat("-", width-len(labels[i])) + link)
+				} else {
+					b.WriteString(labels[i])
+				}
+			}
+			b.WriteString("\n")
+		}
+	}
+	// trailing spaces of the vertical links
+	lines := strings.Split(b.String(), "\n")
+	for i := range lines {
+		lines[i] = strings.TrimRight(lines[i], " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,70 @@
+package tpuinfo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+func fakeDevices(chip *TpuChip, n int) []Device {
+	devices := make([]Device, n)
+	for i := range devices {
+		devices[i] = Device{Index: i, Chip: chip, DevPath: fmt.Sprintf("/dev/accel%d", i)}
+	}
+	return devices
+}
+
+func TestNewTopology(t *testing.T) {
+	for _, tt := range []struct {
+		chip      *TpuChip
+		n         int
+		bounds    [3]int
+		neighbors [][]int
+	}{
+		{&V4, 4, [3]int{2, 2, 1}, [][]int{{1, 2}, {0, 3}, {0, 3}, {1, 2}}},
+		{&V5E, 8, [3]int{2, 4, 1}, [][]int{{1, 2}, {0, 3}, {0, 3, 4}, {1, 2, 5}, {2, 5, 6}, {3, 4, 7}, {4, 7}, {5, 6}}},
+		{&V6E, 4, [3]int{2, 2, 1}, [][]int{{1, 2}, {0, 3}, {0, 3}, {1, 2}}},
+		{&V5E, 1, [3]int{1, 1, 1}, [][]int{nil}},
+	} {
+		topology, err := NewTopology(fakeDevices(tt.chip, tt.n))
+		if err != nil {
+			t.Errorf("%d %s chips: %v", tt.n, tt.chip, err)
+			continue
+		}
+		if topology.Bounds != tt.bounds {
+			t.Errorf("%d %s chips: bounds %v, want %v", tt.n, tt.chip, topology.Bounds, tt.bounds)
+		}
+		var neighbors [][]int
+		for _, chip := range topology.Chips {
+			neighbors = append(neighbors, chip.Neighbors)
+		}
+		if !reflect.DeepEqual(neighbors, tt.neighbors) {
+			t.Errorf("%d %s chips: neighbors %v, want %v", tt.n, tt.chip, neighbors, tt.neighbors)
+		}
+	}
+
+	if _, err := NewTopology(fakeDevices(&V6E, 3)); !errors.Is(err, ErrUnknownTopology) {
+		t.Errorf("3 v6e chips: got %v, want ErrUnknownTopology", err)
+	}
+	mixed := append(fakeDevices(&V5E, 1), fakeDevices(&V6E, 1)...)
+	if _, err := NewTopology(mixed); !errors.Is(err, ErrMixedChipTypes) {
+		t.Errorf("mixed chips: got %v, want ErrMixedChipTypes", err)
+	}
+}
+
+func TestTopologyDiagram(t *testing.T) {
+	topology, err := NewTopology(fakeDevices(&V4, 4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[0 accel0]--[1 accel1]\n" +
+		"     |           |\n" +
+		"[2 accel2]--[3 accel3]\n"
+	if got := topology.Diagram(); got != want {
+		t.Errorf("Diagram() =\n%s\nwant\n%s", got, want)
+	}
+	if chip, ok := topology.At(Coords{X: 1, Y: 1}); !ok || chip.Device.Index != 3 {
+		t.Errorf("At(1,1,0) = %+v, %v", chip, ok)
+	}
+}
@@ -0,0 +1,97 @@
+package tpuinfo
+
+import (
+	"context"
+	"errors"
+	"sort"
+)
+
+// ProcessUsage is the TPU usage of one process: the runtime metrics of the
+// devices on the chips it holds.
+type ProcessUsage struct {
+	Process *Process
+	// Chips are the Device.Index of the chips the process holds and Devices
+	// their canonical device indexes with metrics.
+	Chips   []int
+	Devices []int
+	// MemoryUsage and TotalMemory are summed over Devices, DutyCyclePct is
+	// their mean.
+	MemoryUsage  int64
+	TotalMemory  int64
+	DutyCyclePct float64
+	// Shared is set when another process holds one of the chips too; the
+	// usage of a shared chip is attributed to every holder.
+	Shared bool
+}
+
+// AttributeUsage joins the processes holding each chip with metrics whose
+// DeviceIDs are canonical device indexes, e.g. from GetDiscoveredMetrics.
+// Processes are returned by pid; chips without metrics add nothing.
+func AttributeUsage(mapping []DeviceMapping, procs map[int][]*Process, m *Metrics) []ProcessUsage {
+	byDevice := make(map[int]int)
+	if m != nil {
+		for i, id := range m.DeviceIDs {
+			byDevice[id] = i
+		}
+	}
+	usage := make(map[ProcessID]*ProcessUsage)
+	for chip, chip_procs := range procs {
+		for _, p := range chip_procs {
+			u, ok := usage[p.ID()]
+			if !ok {
+				u = &ProcessUsage{Process: p}
+				usage[p.ID()] = u
+			}
+			u.Chips = append(u.Chips, chip)
+			u.Shared = u.Shared || len(chip_procs) > 1
+		}
+	}
+	result := make([]ProcessUsage, 0, len(usage))
+	for _, u := range usage {
+		sort.Ints(u.Chips)
+		held := make(map[int]bool, len(u.Chips))
+		for _, chip := range u.Chips {
+			held[chip] = true
+		}
+		for _, d := range mapping {
+			i, ok := byDevice[d.Index]
+			if !held[d.Chip] || !ok {
+				continue
+			}
+			u.Devices = append(u.Devices, d.Index)
+			u.MemoryUsage += m.MemoryUsage[i]
+			u.TotalMemory += m.TotalMemory[i]
+			u.DutyCyclePct += m.DutyCyclePct[i]
+		}
+		if len(u.Devices) > 0 {
+			u.DutyCyclePct /= float64(len(u.Devices))
+		}
+		result = append(result, *u)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Process.Pid < result[j].Process.Pid })
+	return result
+}
+
+// GetProcessUsage returns the TPU usage of every process holding a chip. The
+// metrics come from every owner's metrics server (see GetDiscoveredMetrics)
+// or, if none is found, from the default one, whose devices are matched to
+// the canonical index in ascending order. Endpoints that fail are reported in
+// the error next to the usage from the others.
+func GetProcessUsage(ctx context.Context) ([]ProcessUsage, error) {
+	mapping, err := DeviceIndex()
+	if err != nil {
+		return nil, err
+	}
+	procs, err := GetChipProcesses()
+	if err != nil || len(procs) == 0 {
+		return nil, err
+	}
+	m, err := GetDiscoveredMetrics(ctx)
+	if errors.Is(err, ErrNoEndpoints) {
+		m, err = GetMetrics(ctx)
+	}
+	if m == nil {
+		return nil, err
+	}
+	return AttributeUsage(mapping, procs, m), err
+}
@@ -0,0 +1,35 @@
+package tpuinfo
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAttributeUsage(t *testing.T) {
+	// three chips with two cores each
+	var mapping []DeviceMapping
+	for chip := 0; chip < 3; chip++ {
+		for core := 0; core < 2; core++ {
+			mapping = append(mapping, DeviceMapping{Index: len(mapping), Chip: chip, Core: core, RuntimeDeviceID: -1})
+		}
+	}
+	m := &Metrics{
+		DeviceIDs:    []int{0, 1, 2, 3, 4, 5},
+		MemoryUsage:  []int64{1, 2, 3, 4, 5, 6},
+		TotalMemory:  []int64{10, 10, 10, 10, 10, 10},
+		DutyCyclePct: []float64{20, 20, 60, 60, 0, 0},
+	}
+	a := &Process{Pid: 100}
+	b := &Process{Pid: 200}
+	usage := AttributeUsage(mapping, map[int][]*Process{0: {a}, 1: {a, b}, 2: {b}}, m)
+	if len(usage) != 2 {
+		t.Fatalf("got %d processes, want 2", len(usage))
+	}
+	want := ProcessUsage{Process: a, Chips: []int{0, 1}, Devices: []int{0, 1, 2, 3}, MemoryUsage: 10, TotalMemory: 40, DutyCyclePct: 40, Shared: true}
+	if !reflect.DeepEqual(usage[0], want) {
+		t.Errorf("usage of pid 100 = %+v, want %+v", usage[0], want)
+	}
+	if u := usage[1]; u.Process != b || u.MemoryUsage != 18 || u.DutyCyclePct != 30 || !u.Shared {
+		t.Errorf("usage of pid 200 = %+v", u)
+	}
+}
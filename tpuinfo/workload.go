@@ -0,0 +1,296 @@
+package tpuinfo
+
+import (
+	"bufio"
+	"bytes"
+	"debug/elf"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// Framework is the ML framework driving a TPU process.
+type Framework string
+
+const (
+	FrameworkUnknown    Framework = ""
+	FrameworkJAX        Framework = "jax"
+	FrameworkPyTorchXLA Framework = "pytorch-xla"
+	FrameworkTensorFlow Framework = "tensorflow"
+)
+
+// LibTPU describes the libtpu shared library mapped by a process.
+type LibTPU struct {
+	// Path is the path of the library as seen by the process, which may be in
+	// another mount namespace than the caller.
+	Path string
+	// Version is read from the dist-info of the wheel the library came from,
+	// or from the version strings of the library; "" if neither is found.
+	Version string
+	// BuildID is the hex GNU build id from the ELF notes.
+	BuildID string
+}
+
+// libtpuRegex matches the base name of libtpu builds, e.g. libtpu.so.
+var libtpuRegex = regexp.MustCompile(`^libtpu[\w.-]*\.so[\d.]*$`)
+
+// mappedFiles returns the files mapped by a process, from /proc/<pid>/maps,
+// in first-mapped order.
+func mappedFiles(pidStr string) []string {
+	f, err := os.Open(filepath.Join("/proc", pidStr, "maps"))
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+	var files []string
+	seen := make(map[string]bool)
+	s := bufio.NewScanner(f)
+	for s.Scan() {
+		// address perms offset dev inode pathname
+		fields := strings.Fields(s.Text())
+		if len(fields) < 6 || !strings.HasPrefix(fields[5], "/") {
+			continue
+		}
+		path := strings.Join(fields[5:], " ")
+		if !seen[path] {
+			seen[path] = true
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// frameworkMarkers identify a framework by the paths of the libraries it
+// maps, checked in order: PyTorch/XLA and TensorFlow may load parts of each
+// other, neither loads jaxlib.
+var frameworkMarkers = []struct {
+	framework Framework
+	markers   []string
+}{
+	{FrameworkPyTorchXLA, []string{"/torch_xla/", "_XLAC"}},
+	{FrameworkTensorFlow, []string{"libtensorflow_framework.so", "_pywrap_tensorflow"}},
+	{FrameworkJAX, []string{"/jaxlib/", "xla_extension.so"}},
+}
+
+// frameworkModules are the Python packages of each framework, checked in the
+// order of frameworkMarkers.
+var frameworkModules = []struct {
+	framework Framework
+	module    string
+}{
+	{FrameworkPyTorchXLA, "torch_xla"},
+	{FrameworkTensorFlow, "tensorflow"},
+	{FrameworkJAX, "jax"},
+}
+
+// runsModule reports whether a command line runs a module of the Python
+// package module: "-m module[.sub]", or a script inside the package or named
+// after it, e.g. .../site-packages/module/tool.py or module.py. Other
+// arguments, such as --out=/data/jax_runs, are ignored.
+func runsModule(cmdline []string, module string) bool {
+	for i, arg := range cmdline {
+		if name, ok := strings.CutPrefix(arg, "-m"); ok {
+			if name == "" && i+1 < len(cmdline) {
+				name = cmdline[i+1]
+			}
+			if top, _, _ := strings.Cut(name, "."); top == module {
+				return true
+			}
+			continue
+		}
+		if strings.HasPrefix(arg, "-") || !strings.HasSuffix(arg, ".py") {
+			continue
+		}
+		if strings.TrimSuffix(filepath.Base(arg), ".py") == module ||
+			strings.HasPrefix(arg, module+"/") || strings.Contains(arg, "/"+module+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// classifyFramework identifies the framework from the mapped libraries and,
+// failing that, from the module or script the command line runs.
+func classifyFramework(files, cmdline []string) Framework {
+	for _, fm := range frameworkMarkers {
+		for _, file := range files {
+			for _, marker := range fm.markers {
+				if strings.Contains(file, marker) {
+					return fm.framework
+				}
+			}
+		}
+	}
+	for _, fm := range frameworkModules {
+		if runsModule(cmdline, fm.module) {
+			return fm.framework
+		}
+	}
+	return FrameworkUnknown
+}
+
+// libtpuKey identifies a libtpu file by device and inode, so that processes
+// mapping the same library through different mount namespaces share a cache
+// entry, and by size and modification time in case it was replaced in place.
+type libtpuKey struct {
+	dev       uint64
+	ino       uint64
+	size      int64
+	modTimeNs int64
+}
+
+var (
+	libtpu_mu    sync.Mutex
+	libtpu_cache = make(map[libtpuKey]LibTPU)
+)
+
+// readLibTPU describes the libtpu mapped by a process, nil if there is none,
+// and returns the key it is cached under. The library is opened through
+// /proc/<pid>/root so that libraries inside containers are found.
+func readLibTPU(pidStr string, files []string) (*LibTPU, libtpuKey) {
+	for _, file := range files {
+		if !libtpuRegex.MatchString(filepath.Base(file)) {
+			continue
+		}
+		lib := &LibTPU{Path: file}
+		root := filepath.Join("/proc", pidStr, "root")
+		path := filepath.Join(root, file)
+		fi, err := os.Stat(path)
+		if err != nil {
+			// e.g. /proc/<pid>/root of another user's process
+			return lib, libtpuKey{}
+		}
+		dev, ino := fileID(fi)
+		key := libtpuKey{dev, ino, fi.Size(), fi.ModTime().UnixNano()}
+		libtpu_mu.Lock()
+		cached, ok := libtpu_cache[key]
+		libtpu_mu.Unlock()
+		if ok {
+			cached.Path = file
+			return &cached, key
+		}
+		lib.Version = distInfoVersion(root, file)
+		if f, err := elf.Open(path); err == nil {
+			lib.BuildID = elfBuildID(f)
+			if lib.Version == "" {
+				lib.Version = elfVersion(f)
+			}
+			f.Close()
+		}
+		libtpu_mu.Lock()
+		libtpu_cache[key] = *lib
+		libtpu_mu.Unlock()
+		return lib, key
+	}
+	return nil, libtpuKey{}
+}
+
+// forgetLibTPUs drops the cached libraries no process in keep maps.
+func forgetLibTPUs(keep map[int64]*Process) {
+	used := make(map[libtpuKey]bool)
+	for _, p := range keep {
+		used[p.libtpu] = true
+	}
+	libtpu_mu.Lock()
+	defer libtpu_mu.Unlock()
+	for key := range libtpu_cache {
+		if !used[key] {
+			delete(libtpu_cache, key)
+		}
+	}
+}
+
+// distInfoRegex matches the dist-info directory of the libtpu wheels, e.g.
+// libtpu-0.0.11.dist-info or libtpu_nightly-0.1.dev20241201.dist-info.
+var distInfoRegex = regexp.MustCompile(`^libtpu(?:[_-]nightly)?-([^-]+)\.dist-info$`)
+
+// distInfoVersion finds the version of the wheel installing file, a libtpu
+// in site-packages/libtpu/ or site-packages/.
+func distInfoVersion(root, file string) string {
+	for _, dir := range []string{filepath.Dir(filepath.Dir(file)), filepath.Dir(file)} {
+		entries, err := os.ReadDir(filepath.Join(root, dir))
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			if m := distInfoRegex.FindStringSubmatch(e.Name()); m != nil {
+				return m[1]
+			}
+		}
+	}
+	return ""
+}
+
+// elfBuildID returns the NT_GNU_BUILD_ID note of an ELF file in hex.
+func elfBuildID(f *elf.File) string {
+	const ntGNUBuildID = 3
+	for _, p := range f.Progs {
+		if p.Type != elf.PT_NOTE {
+			continue
+		}
+		notes, err := io.ReadAll(p.Open())
+		if err != nil {
+			continue
+		}
+		// namesz, descsz, type, name and desc, each padded to 4 bytes
+		for len(notes) >= 12 {
+			namesz := int(f.ByteOrder.Uint32(notes[0:4]))
+			descsz := int(f.ByteOrder.Uint32(notes[4:8]))
+			typ := f.ByteOrder.Uint32(notes[8:12])
+			name := 12 + (namesz+3)&^3
+			end := name + (descsz+3)&^3
+			if end > len(notes) || namesz > len(notes) {
+				break
+			}
+			if typ == ntGNUBuildID && bytes.Equal(bytes.TrimRight(notes[12:12+namesz], "\x00"), []byte("GNU")) {
+				return hex.EncodeToString(notes[name : name+descsz])
+			}
+			notes = notes[end:]
+		}
+	}
+	return ""
+}
+
+// elfVersionRegex matches the version strings embedded in libtpu builds. Its
+// repetitions are bounded so that a match is at most versionMaxLen bytes.
+var elfVersionRegex = regexp.MustCompile(`libtpu[ _-](?:version[ :=]{0,3})?v?(\d{1,6}\.\d{1,6}\.\d{1,6}(?:[.+-][0-9A-Za-z.]{1,64})?)`)
+
+const (
+	// maxVersionScan bounds how much of .rodata is scanned for a version
+	// string, read versionChunk bytes at a time.
+	maxVersionScan = 64 << 20
+	versionChunk   = 1 << 20
+	versionMaxLen  = 128
+)
+
+// elfVersion scans the read-only data of an ELF file for a libtpu version
+// string, "" if there is none. The section is read in chunks; the last
+// 2*versionMaxLen bytes of each chunk are scanned again with the next one so
+// that a string straddling two chunks is neither missed nor truncated.
+func elfVersion(f *elf.File) string {
+	s := f.Section(".rodata")
+	if s == nil {
+		return ""
+	}
+	r := io.LimitReader(s.Open(), maxVersionScan)
+	buf := make([]byte, 2*versionMaxLen+versionChunk)
+	kept := 0
+	for {
+		n, err := io.ReadFull(r, buf[kept:])
+		data := buf[:kept+n]
+		done := err != nil
+		// a match ending near the end of the chunk may continue in the next
+		if m := elfVersionRegex.FindSubmatchIndex(data); m != nil && (done || m[1] <= len(data)-versionMaxLen) {
+			return string(data[m[2]:m[3]])
+		}
+		if done {
+			// io.EOF or io.ErrUnexpectedEOF at the end of the section
+			return ""
+		}
+		kept = copy(buf, data[len(data)-2*versionMaxLen:])
+	}
+}
@@ -0,0 +1,192 @@
+package tpuinfo
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// buildELF returns a minimal 64-bit little-endian ELF file with a GNU build id
+// note, in a PT_NOTE segment, and a .rodata section.
+func buildELF(t *testing.T, buildID, rodata []byte) *elf.File {
+	t.Helper()
+	note := new(bytes.Buffer)
+	binary.Write(note, binary.LittleEndian, [3]uint32{4, uint32(len(buildID)), 3})
+	note.WriteString("GNU\x00")
+	note.Write(buildID)
+	for note.Len()%4 != 0 {
+		note.WriteByte(0)
+	}
+	shstrtab := "\x00.note.gnu.build-id\x00.rodata\x00.shstrtab\x00"
+
+	const headers = 64 + 56
+	noteOff := uint64(headers)
+	rodataOff := noteOff + uint64(note.Len())
+	strtabOff := rodataOff + uint64(len(rodata))
+	shOff := (strtabOff + uint64(len(shstrtab)) + 7) &^ 7
+
+	var b bytes.Buffer
+	header := elf.Header64{
+		Type: uint16(elf.ET_DYN), Machine: uint16(elf.EM_X86_64), Version: uint32(elf.EV_CURRENT),
+		Phoff: 64, Shoff: shOff, Ehsize: 64, Phentsize: 56, Phnum: 1, Shentsize: 64, Shnum: 4, Shstrndx: 3,
+	}
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+	binary.Write(&b, binary.LittleEndian, header)
+	binary.Write(&b, binary.LittleEndian, elf.Prog64{
+		Type: uint32(elf.PT_NOTE), Flags: uint32(elf.PF_R), Off: noteOff, Filesz: uint64(note.Len()), Memsz: uint64(note.Len()), Align: 4,
+	})
+	b.Write(note.Bytes())
+	b.Write(rodata)
+	b.WriteString(shstrtab)
+	for uint64(b.Len()) < shOff {
+		b.WriteByte(0)
+	}
+	for _, s := range []elf.Section64{
+		{},
+		{Name: 1, Type: uint32(elf.SHT_NOTE), Flags: uint64(elf.SHF_ALLOC), Off: noteOff, Size: uint64(note.Len()), Addralign: 4},
+		{Name: 20, Type: uint32(elf.SHT_PROGBITS), Flags: uint64(elf.SHF_ALLOC), Off: rodataOff, Size: uint64(len(rodata)), Addralign: 1},
+		{Name: 28, Type: uint32(elf.SHT_STRTAB), Off: strtabOff, Size: uint64(len(shstrtab)), Addralign: 1},
+	} {
+		binary.Write(&b, binary.LittleEndian, s)
+	}
+	f, err := elf.NewFile(bytes.NewReader(b.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return f
+}
+
+func TestELFBuildIDAndVersion(t *testing.T) {
+	id := []byte{0xde, 0xad, 0xbe, 0xef, 0x01, 0x02, 0x03, 0x04}
+	f := buildELF(t, id, []byte("\x00abc\x00libtpu version: 0.0.11\x00"))
+	if got := elfBuildID(f); got != "deadbeef01020304" {
+		t.Errorf("elfBuildID() = %q, want deadbeef01020304", got)
+	}
+	if got := elfVersion(f); got != "0.0.11" {
+		t.Errorf("elfVersion() = %q, want 0.0.11", got)
+	}
+	if got := elfVersion(buildELF(t, id, []byte("no version here"))); got != "" {
+		t.Errorf("elfVersion() without a version = %q", got)
+	}
+}
+
+func TestELFVersionAcrossChunks(t *testing.T) {
+	const version = "libtpu_version=0.0.11+nightly.20241201"
+	// the first read fills the chunk and the carried tail
+	boundary := versionChunk + 2*versionMaxLen
+	for _, at := range []int{1, 10, len(version) - 1, len(version), versionMaxLen + 5} {
+		rodata := bytes.Repeat([]byte{' '}, boundary+versionChunk/2)
+		copy(rodata[boundary-at:], version)
+		f := buildELF(t, []byte{1, 2, 3, 4}, rodata)
+		if got := elfVersion(f); got != "0.0.11+nightly.20241201" {
+			t.Errorf("version %d bytes before the chunk end: got %q", at, got)
+		}
+	}
+}
+
+func TestDistInfoVersion(t *testing.T) {
+	root := t.TempDir()
+	site := filepath.Join(root, "usr", "lib", "python3", "site-packages")
+	for _, dir := range []string{filepath.Join(site, "libtpu"), filepath.Join(site, "libtpu-0.0.11.dist-info")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := distInfoVersion(root, "/usr/lib/python3/site-packages/libtpu/libtpu.so"); got != "0.0.11" {
+		t.Errorf("distInfoVersion() = %q, want 0.0.11", got)
+	}
+	if got := distInfoVersion(root, "/opt/libtpu.so"); got != "" {
+		t.Errorf("distInfoVersion() outside a wheel = %q", got)
+	}
+}
+
+func TestClassifyFramework(t *testing.T) {
+	site := "/usr/lib/python3/site-packages"
+	for _, tt := range []struct {
+		name    string
+		files   []string
+		cmdline string
+		want    Framework
+	}{
+		{"jax", []string{"/usr/bin/python3", site + "/jaxlib/xla_extension.so", site + "/libtpu/libtpu.so"}, "python3 train.py", FrameworkJAX},
+		{"pytorch-xla", []string{site + "/torch/lib/libtorch.so", site + "/_XLAC.cpython-311-x86_64-linux-gnu.so"}, "python3 train.py", FrameworkPyTorchXLA},
+		{"torch_xla loading jaxlib", []string{site + "/jaxlib/xla_extension.so", site + "/torch_xla/lib/libfoo.so"}, "python3 train.py", FrameworkPyTorchXLA},
+		{"tensorflow", []string{site + "/tensorflow/libtensorflow_framework.so.2"}, "python3 train.py", FrameworkTensorFlow},
+		{"command line", []string{"/usr/bin/python3"}, "python3 -m jax.tools.run", FrameworkJAX},
+		{"module without a space", []string{"/usr/bin/python3"}, "python3 -mtorch_xla.distributed.xla_dist", FrameworkPyTorchXLA},
+		{"script in a package", []string{"/usr/bin/python3"}, "python3 " + site + "/tensorflow/python/tools/saved_model_cli.py", FrameworkTensorFlow},
+		{"unrelated arguments", []string{"/usr/bin/python3"}, "python3 serve.py --out=/data/jax_runs --model tensorflow_hub /data/jax/ckpt", FrameworkUnknown},
+		{"module name prefix", []string{"/usr/bin/python3"}, "python3 -m jaxtyping.check", FrameworkUnknown},
+		{"unknown", []string{"/usr/bin/python3", site + "/libtpu/libtpu.so"}, "python3 serve.py", FrameworkUnknown},
+	} {
+		if got := classifyFramework(tt.files, strings.Fields(tt.cmdline)); got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLibTPURegex(t *testing.T) {
+	for name, want := range map[string]bool{
+		"libtpu.so":            true,
+		"libtpu.so.1":          true,
+		"libtpu-nightly.so":    true,
+		"libtpu_internal.so.0": true,
+		"libtorch.so":          false,
+		"libtpu.py":            false,
+	} {
+		if got := libtpuRegex.MatchString(name); got != want {
+			t.Errorf("libtpuRegex.MatchString(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestLibTPUCache(t *testing.T) {
+	site := filepath.Join(t.TempDir(), "site-packages")
+	for _, dir := range []string{filepath.Join(site, "libtpu"), filepath.Join(site, "libtpu-0.0.11.dist-info")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	file := filepath.Join(site, "libtpu", "libtpu.so")
+	if err := os.WriteFile(file, []byte("not an ELF file"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	// the same file seen through two /proc/<pid>/root paths
+	lib, key := readLibTPU("self", []string{file})
+	if lib == nil || lib.Version != "0.0.11" || lib.Path != file {
+		t.Fatalf("readLibTPU() = %+v, want version 0.0.11 of %s", lib, file)
+	}
+	libtpu_mu.Lock()
+	before := len(libtpu_cache)
+	libtpu_mu.Unlock()
+	_, other := readLibTPU(strconv.Itoa(os.Getpid()), []string{file})
+	libtpu_mu.Lock()
+	after := len(libtpu_cache)
+	libtpu_mu.Unlock()
+	if other != key || after != before {
+		t.Errorf("the same library through another pid added a cache entry: %d -> %d", before, after)
+	}
+
+	forgetLibTPUs(map[int64]*Process{1: {Pid: 1, libtpu: key}})
+	libtpu_mu.Lock()
+	_, kept := libtpu_cache[key]
+	libtpu_mu.Unlock()
+	if !kept {
+		t.Error("forgetLibTPUs dropped a library still in use")
+	}
+	forgetLibTPUs(nil)
+	libtpu_mu.Lock()
+	_, kept = libtpu_cache[key]
+	libtpu_mu.Unlock()
+	if kept {
+		t.Error("forgetLibTPUs kept a library no process maps")
+	}
+}